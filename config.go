@@ -0,0 +1,295 @@
+package gostruct
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configFile is the name of the configuration file, which is looked up in the working directory & its parents
+const configFile = "gostruct.yaml"
+
+// Config is the content of a gostruct.yaml file. Flags that are passed override its settings, which override the
+// settings set in Go code
+type Config struct {
+	// Database is the database to generate when the db flag isn't passed
+	Database string `yaml:"database"`
+	// Connections holds the connection settings by database. The settings of the generated database are used
+	// for introspection, and the connection package connects to every database with its own settings
+	Connections map[string]ConnectionConfig `yaml:"connections"`
+	// Tables selects the tables to generate when neither the tables nor the all flag is passed
+	Tables TableFilter `yaml:"tables"`
+	// Output holds the directories of the generated packages
+	Output OutputConfig `yaml:"output"`
+	// Naming holds the naming options of the generated code
+	Naming NamingConfig `yaml:"naming"`
+	// TypeOverrides maps columns or database types to custom Go types
+	TypeOverrides []TypeOverride `yaml:"type_overrides"`
+	// Features holds the feature toggles by table
+	Features map[string]TableFeatures `yaml:"features"`
+	// Procs holds the settings of the procs package
+	Procs ProcsConfig `yaml:"procs"`
+}
+
+// ConnectionConfig holds the connection settings of a database
+type ConnectionConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// TableFilter selects tables by glob patterns, e.g. user_*, or by regular expressions wrapped in slashes, e.g.
+// /^tmp_[0-9]+$/. A table is generated when it matches an include pattern, or when there are none, doesn't match
+// any exclude pattern, has one of the types and its comment doesn't contain the skip marker
+type TableFilter struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// Types are the table types to generate, e.g. BASE TABLE or VIEW. Every type is generated when it is empty
+	Types []string `yaml:"types"`
+	// SkipMarker leaves out the tables whose comment contains it. It defaults to gostruct:skip
+	SkipMarker string `yaml:"skip_marker"`
+}
+
+// OutputConfig holds the directories of the generated packages, relative to $GOPATH/src
+type OutputConfig struct {
+	DBDir    string `yaml:"db_dir"`
+	ModelDir string `yaml:"model_dir"`
+	// ProcsDir is the directory of the procs package. It defaults to {model_dir}/procs
+	ProcsDir string `yaml:"procs_dir"`
+}
+
+// NamingConfig holds the naming options of the generated code
+type NamingConfig struct {
+	// NameFuncs includes the struct name in the generated function names. It is left as set in Go code when
+	// it is missing
+	NameFuncs *bool `yaml:"name_funcs"`
+}
+
+// TableFeatures toggles features of the package of a single table
+type TableFeatures struct {
+	// SoftDelete is a nullable date, datetime or timestamp column. Delete sets it to the current time instead
+	// of removing the record, and the records it is set for are left out of ReadByKey & ReadAll
+	SoftDelete string `yaml:"soft_delete"`
+	// Version is an integer column used for optimistic locking. Save only updates a record when its version
+	// still matches the stored one, increments it, and returns connection.ErrStale otherwise
+	Version string `yaml:"version"`
+	// ReadOnly leaves out Save & Delete. The packages of views are always read-only
+	ReadOnly bool `yaml:"read_only"`
+	// Key is the comma separated list of the columns ReadByKey reads the records of a view or read-only table
+	// by, instead of the primary key
+	Key string `yaml:"key"`
+}
+
+// tableFeatures returns the features of a table, which is always read-only for a view, and makes sure the
+// columns they refer to exist and have a supported type
+func (g Gostruct) tableFeatures(table string, schema tableSchema) (TableFeatures, error) {
+	f := g.Features[table]
+	f.ReadOnly = f.ReadOnly || schema.View
+	if f.ReadOnly && (f.SoftDelete != "" || f.Version != "") {
+		return f, errors.New("config error: " + table + " is read-only and can't have a soft delete or version column")
+	}
+	if f.Key != "" && !f.ReadOnly {
+		return f, errors.New("config error: the key of " + table + " only applies to views & read-only tables")
+	}
+
+	var hasKey, hasSoftDelete, hasVersion bool
+	columns := map[string]bool{}
+	for _, object := range schema.Columns {
+		columns[object.Name] = true
+		fieldType, _ := g.columnType(table, object)
+		switch {
+		case object.Key == "PRI":
+			hasKey = true
+		case object.Name == f.SoftDelete:
+			if fieldType.Type != "*time.Time" {
+				return f, errors.New("config error: soft delete column " + table + "." + object.Name + " must be a nullable date, datetime or timestamp")
+			}
+			hasSoftDelete = true
+		case object.Name == f.Version:
+			switch fieldType.Type {
+			case "int64", "uint8", "uint16", "uint32", "uint64":
+			default:
+				return f, errors.New("config error: version column " + table + "." + object.Name + " must be a NOT NULL integer")
+			}
+			hasVersion = true
+		}
+	}
+
+	for _, column := range splitList(f.Key) {
+		if !columns[column] {
+			return f, errors.New("config error: key column " + table + "." + column + " doesn't exist")
+		}
+	}
+
+	switch {
+	case f.SoftDelete != "" && !hasSoftDelete:
+		return f, errors.New("config error: soft delete column " + table + "." + f.SoftDelete + " doesn't exist")
+	case f.Version != "" && !hasVersion:
+		return f, errors.New("config error: version column " + table + "." + f.Version + " doesn't exist")
+	case (hasSoftDelete || hasVersion) && !hasKey:
+		return f, errors.New("config error: " + table + " needs a primary key for a soft delete or version column")
+	}
+
+	return f, nil
+}
+
+// isKey determines whether a column is part of the key ReadByKey reads records by: the logical key when one is
+// set, the primary key otherwise
+func (f TableFeatures) isKey(object tableObj) bool {
+	if f.Key == "" {
+		return object.Key == "PRI"
+	}
+
+	for _, column := range splitList(f.Key) {
+		if column == object.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcsConfig holds the settings of the procs package, which wraps the stored procedures & functions
+type ProcsConfig struct {
+	// Generate generates the procs package. It is left as set in Go code when it is missing
+	Generate *bool `yaml:"generate"`
+	// Results holds the columns of the result set of every stored procedure that returns rows, by procedure
+	Results map[string][]ResultColumn `yaml:"results"`
+}
+
+// ResultColumn is a column of the result set of a stored procedure. The columns are scanned in order
+type ResultColumn struct {
+	Name string `yaml:"name"`
+	// Type is the MySQL column type, e.g. decimal(10,2) unsigned
+	Type string `yaml:"type"`
+	Null bool   `yaml:"null"`
+}
+
+// findConfig returns the path of the gostruct.yaml file in the working directory or the closest parent
+// directory, or an empty string when there is none
+func findConfig() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path := filepath.Join(dir, configFile)
+		if exists(path) {
+			return path, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// loadConfig reads a configuration file
+func loadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	err = yaml.UnmarshalStrict(data, &config)
+	return config, err
+}
+
+// applyConfig copies the settings of a configuration file onto the generator. The settings of the file override
+// the ones set in Go code, while the settings missing from the file are kept
+func (g *Gostruct) applyConfig(config Config) {
+	if config.Database != "" {
+		g.Database = config.Database
+	}
+	if len(config.Connections) > 0 {
+		connections := map[string]ConnectionConfig{}
+		for database, c := range g.Connections {
+			connections[database] = c
+		}
+		for database, c := range config.Connections {
+			connections[database] = c
+		}
+		g.Connections = connections
+	}
+	if config.Tables.Include != nil || config.Tables.Exclude != nil {
+		g.Include, g.Exclude = config.Tables.Include, config.Tables.Exclude
+	}
+	if config.Tables.Types != nil {
+		g.TableTypes = config.Tables.Types
+	}
+	if config.Tables.SkipMarker != "" {
+		g.SkipMarker = config.Tables.SkipMarker
+	}
+	if config.Naming.NameFuncs != nil {
+		g.NameFuncs = *config.Naming.NameFuncs
+	}
+	// the first matching override is used, so the overrides of the file come first
+	g.TypeOverrides = append(append([]TypeOverride{}, config.TypeOverrides...), g.TypeOverrides...)
+	if len(config.Features) > 0 {
+		features := map[string]TableFeatures{}
+		for table, f := range g.Features {
+			features[table] = f
+		}
+		for table, f := range config.Features {
+			features[table] = f
+		}
+		g.Features = features
+	}
+	if config.Procs.Generate != nil {
+		g.Procs = *config.Procs.Generate
+	}
+	if len(config.Procs.Results) > 0 {
+		results := map[string][]ResultColumn{}
+		for proc, columns := range g.ProcResults {
+			results[proc] = columns
+		}
+		for proc, columns := range config.Procs.Results {
+			results[proc] = columns
+		}
+		g.ProcResults = results
+	}
+}
+
+// applyConnection copies the connection settings of the generated database onto the generator, overriding the
+// ones set in Go code. Settings missing from the connection are kept
+func (g *Gostruct) applyConnection() {
+	c, ok := g.Connections[g.Database]
+	if !ok {
+		return
+	}
+
+	for _, s := range []struct {
+		field *string
+		value string
+	}{
+		{&g.Host, c.Host},
+		{&g.Port, c.Port},
+		{&g.Username, c.Username},
+		{&g.Password, c.Password},
+	} {
+		if s.value != "" {
+			*s.field = s.value
+		}
+	}
+}
+
+// dataSourceName returns the data source name the connection package uses for a configured database. The
+// username & password of the generator are used when the connection settings leave them out
+func (g Gostruct) dataSourceName(database string) string {
+	c := g.Connections[database]
+	if c.Username == "" && c.Password == "" {
+		c.Username, c.Password = g.Username, g.Password
+	}
+	if c.Port == "" {
+		c.Port = "3306"
+	}
+
+	return c.Username + ":" + c.Password + "@tcp(" + c.Host + ":" + c.Port + ")/" + database + "?parseTime=true"
+}
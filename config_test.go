@@ -0,0 +1,108 @@
+package gostruct
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFile)
+	err := os.WriteFile(path, []byte(`database: app
+connections:
+  app:
+    host: localhost
+    port: "3307"
+tables:
+  include: [user*, role]
+  exclude: [user_archive]
+output:
+  model_dir: models
+naming:
+  name_funcs: true
+type_overrides:
+  - column: user.amount
+    go_type: money.Amount
+    import: example.com/money
+features:
+  post:
+    soft_delete: deleted_at
+    version: revision
+`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Output.ModelDir != "models" {
+		t.Errorf("model_dir: got %q, want models", config.Output.ModelDir)
+	}
+
+	// the file overrides the settings set in Go code and keeps the ones it leaves out
+	g := Gostruct{Username: "user", Host: "db.internal", Procs: true}
+	g.applyConfig(config)
+	g.applyConnection()
+	if g.Database != "app" || g.Host != "localhost" || g.Port != "3307" || !g.NameFuncs || !g.Procs {
+		t.Errorf("got database %q, host %q, port %q, nameFuncs %v, procs %v", g.Database, g.Host, g.Port, g.NameFuncs, g.Procs)
+	}
+
+	off := false
+	disabled := Gostruct{NameFuncs: true, Procs: true}
+	disabled.applyConfig(Config{Naming: NamingConfig{NameFuncs: &off}, Procs: ProcsConfig{Generate: &off}})
+	if disabled.NameFuncs || disabled.Procs {
+		t.Errorf("name_funcs & generate set to false: got nameFuncs %v, procs %v", disabled.NameFuncs, disabled.Procs)
+	}
+	if want := []TypeOverride{{Column: "user.amount", GoType: "money.Amount", Import: "example.com/money"}}; !reflect.DeepEqual(g.TypeOverrides, want) {
+		t.Errorf("type overrides: got %+v, want %+v", g.TypeOverrides, want)
+	}
+	if want := (TableFeatures{SoftDelete: "deleted_at", Version: "revision"}); g.Features["post"] != want {
+		t.Errorf("features: got %+v, want %+v", g.Features["post"], want)
+	}
+	if got, want := g.dataSourceName("app"), "user:@tcp(localhost:3307)/app?parseTime=true"; got != want {
+		t.Errorf("data source: got %q, want %q", got, want)
+	}
+
+	for table, want := range map[string]bool{"user": true, "user_role": true, "role": true, "user_archive": false, "post": false} {
+		if got := g.includeTable(table); got != want {
+			t.Errorf("includeTable(%q): got %v, want %v", table, got, want)
+		}
+	}
+
+	err = os.WriteFile(path, []byte("tables:\n  includes: [user]\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = loadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "includes") {
+		t.Errorf("unknown setting: got %v", err)
+	}
+}
+
+func TestTableFeatures(t *testing.T) {
+	for _, test := range []struct {
+		features TableFeatures
+		err      string
+	}{
+		{TableFeatures{SoftDelete: "deleted_at", Version: "revision"}, ""},
+		{TableFeatures{SoftDelete: "title"}, "soft delete column post.title must be a nullable date"},
+		{TableFeatures{SoftDelete: "removed"}, "soft delete column post.removed doesn't exist"},
+		{TableFeatures{Version: "deleted_at"}, "version column post.deleted_at must be a NOT NULL integer"},
+		{TableFeatures{ReadOnly: true, Version: "revision"}, "post is read-only"},
+		{TableFeatures{Key: "title"}, "the key of post only applies to views & read-only tables"},
+		{TableFeatures{ReadOnly: true, Key: "title, slug"}, "key column post.slug doesn't exist"},
+	} {
+		g := Gostruct{Features: map[string]TableFeatures{"post": test.features}}
+		_, err := g.tableFeatures("post", fixtures["post"])
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%+v: %v", test.features, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%+v: got %v, want %q", test.features, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package gostruct
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around every change of a diff
+const diffContext = 3
+
+// diffLine is a single line of a diff: ' ' for an unchanged line, '-' for a removed line & '+' for an added line
+type diffLine struct {
+	Kind byte
+	Text string
+}
+
+// preview reports whether generated files are only reported instead of written
+func (g Gostruct) preview() bool {
+	return g.DryRun || g.Diff
+}
+
+// reportChange reports what writing src to path would change: the status of the file in dry-run mode and its
+// unified diff in diff mode. Existing files are left untouched unless overwrite is set
+func (g Gostruct) reportChange(path, src string, overwrite bool) error {
+	var old string
+	status := "created"
+	if exists(path) {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		old = string(contents)
+
+		status = "modified"
+		if !overwrite || old == src {
+			status, src = "unchanged", old
+		}
+	}
+
+	var out string
+	if g.DryRun {
+		out += fmt.Sprintf("%-9s %s\n", status, path)
+	}
+	if g.Diff && status != "unchanged" {
+		oldName := path
+		if status == "created" {
+			oldName = "/dev/null"
+		}
+		out += unifiedDiff(oldName, path, old, src)
+	}
+
+	if out != "" {
+		g.print(out)
+	}
+	return nil
+}
+
+// removeFile removes a generated file that is no longer needed. Dry-run & diff mode report the removal instead
+func (g Gostruct) removeFile(path string) error {
+	if !exists(path) {
+		return nil
+	}
+	if !g.preview() {
+		return os.Remove(path)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var out string
+	if g.DryRun {
+		out += fmt.Sprintf("%-9s %s\n", "deleted", path)
+	}
+	if g.Diff {
+		out += unifiedDiff(path, "/dev/null", string(contents), "")
+	}
+	g.print(out)
+	return nil
+}
+
+// print prints the output of a worker through the handler, so the output of concurrent workers doesn't mix
+func (g Gostruct) print(out string) {
+	if g.output == nil {
+		fmt.Print(out)
+		return
+	}
+	g.output <- out
+}
+
+// unifiedDiff returns the differences between two versions of a file in the unified format, or an empty
+// string when they are equal
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+	out := "--- " + oldName + "\n+++ " + newName + "\n"
+
+	// oldLine & newLine count the lines of both versions in front of i
+	var oldLine, newLine int
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// a hunk starts with the context in front of the change and includes every following change that's
+		// close enough for the contexts to touch
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for {
+			for end < len(lines) && lines[end].Kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].Kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		end += diffContext
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		var oldCount, newCount int
+		var body string
+		for _, line := range lines[start:end] {
+			if line.Kind != '+' {
+				oldCount++
+			}
+			if line.Kind != '-' {
+				newCount++
+			}
+			body += string(line.Kind) + line.Text
+			if !strings.HasSuffix(line.Text, "\n") {
+				body += "\n\\ No newline at end of file\n"
+			}
+		}
+		out += fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount)) + body
+
+		oldLine, newLine = oldStart+oldCount, newStart+newCount
+		i = end
+	}
+
+	return out
+}
+
+// hunkRange returns the range of a hunk header. start is the number of lines in front of the hunk
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines, keeping the line endings
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b, based on their longest common subsequence. The
+// common prefix & suffix are skipped, since generated files usually only change in a few places
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{Kind: ' ', Text: text})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] & y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{Kind: ' ', Text: x[i]})
+			i++
+			j++
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{Kind: '-', Text: x[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{Kind: '+', Text: y[j]})
+			j++
+		}
+	}
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{Kind: ' ', Text: text})
+	}
+
+	return lines
+}
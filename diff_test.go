@@ -0,0 +1,33 @@
+package gostruct
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nn\no"
+
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,5 +10,5 @@
+ j
+ k
+ l
+-m
+ n
++o
+\ No newline at end of file
+`
+	if got := unifiedDiff("old", "new", old, new); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := unifiedDiff("old", "new", old, old); got != "" {
+		t.Errorf("got %q for equal files", got)
+	}
+}
@@ -0,0 +1,213 @@
+package gostruct
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// enumValues parses the allowed values out of an enum or set column type such as enum('a','b')
+func enumValues(columnType string) []string {
+	start := strings.Index(columnType, "(")
+	end := strings.LastIndex(columnType, ")")
+	if start < 0 || end < start {
+		return nil
+	}
+
+	var values []string
+	var current []rune
+	inQuote := false
+	runes := []rune(columnType[start+1 : end])
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' && inQuote && i+1 < len(runes) && runes[i+1] == '\'':
+			// quotes are escaped by doubling them
+			current = append(current, c)
+			i++
+		case c == '\'':
+			if inQuote {
+				values = append(values, string(current))
+				current = current[:0]
+			}
+			inQuote = !inQuote
+		case inQuote:
+			current = append(current, c)
+		}
+	}
+
+	return values
+}
+
+// enumIdentifiers returns a unique Go identifier suffix for each enum or set value
+func enumIdentifiers(values []string) []string {
+	var idents []string
+	used := make(map[string]bool)
+	for _, value := range values {
+		var ident string
+		for _, part := range strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			first, size := utf8.DecodeRuneInString(part)
+			ident += string(unicode.ToUpper(first)) + part[size:]
+		}
+		if ident == "" {
+			ident = "Empty"
+		}
+		// a numbered suffix can itself be taken by a later value, so keep counting until it is free
+		for base, n := ident, len(idents); used[ident]; n++ {
+			ident = base + strconv.Itoa(n)
+		}
+		used[ident] = true
+		idents = append(idents, ident)
+	}
+
+	return idents
+}
+
+// enumTypeName returns the name of the generated enum or set type for a column
+func enumTypeName(table, column string) string {
+	return uppercaseFirst(table) + uppercaseFirst(column)
+}
+
+// buildEnumType returns a named string type with a constant for each allowed value of an enum column
+func buildEnumType(table string, object tableObj) string {
+	typeName := enumTypeName(table, object.Name)
+	values := enumValues(object.ColumnType)
+	idents := enumIdentifiers(values)
+
+	var constants, cases string
+	for i, value := range values {
+		constants += "\n\t" + typeName + idents[i] + " " + typeName + " = " + strconv.Quote(value)
+		if i > 0 {
+			cases += ", "
+		}
+		cases += typeName + idents[i]
+	}
+
+	return `
+
+// ` + typeName + ` is the type of the enum ` + object.Name + ` column
+type ` + typeName + ` string
+
+// Allowed values of the ` + object.Name + ` column
+const (` + constants + `
+)
+
+// Valid determines whether the value is one of the allowed values of the ` + object.Name + ` column
+func (e ` + typeName + `) Valid() bool {
+	switch e {
+	case ` + cases + `:
+		return true
+	}
+	return false
+}
+
+// Scan implements the sql.Scanner interface
+func (e *` + typeName + `) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		*e = ` + typeName + `(v)
+	case string:
+		*e = ` + typeName + `(v)
+	case nil:
+		*e = ""
+	default:
+		return fmt.Errorf("cannot scan %T into ` + typeName + `", src)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (e ` + typeName + `) Value() (driver.Value, error) {
+	return string(e), nil
+}`
+}
+
+// buildSetType returns a bit flag type with a constant for each member of a set column. The bits follow
+// the order of the members, the same way MySQL stores them
+func buildSetType(table string, object tableObj) string {
+	typeName := enumTypeName(table, object.Name)
+	membersVar := lowercaseFirst(typeName) + "Members"
+	values := enumValues(object.ColumnType)
+	idents := enumIdentifiers(values)
+
+	var constants, members string
+	for i, value := range values {
+		constants += "\n\t" + typeName + idents[i]
+		if i == 0 {
+			constants += " " + typeName + " = 1 << iota"
+		} else {
+			members += ", "
+		}
+		members += strconv.Quote(value)
+	}
+
+	return `
+
+// ` + typeName + ` is the type of the set ` + object.Name + ` column. Each member is a single bit flag
+type ` + typeName + ` uint64
+
+// Members of the ` + object.Name + ` column
+const (` + constants + `
+)
+
+var ` + membersVar + ` = []string{` + members + `}
+
+// Valid determines whether only members of the ` + object.Name + ` column are set
+func (s ` + typeName + `) Valid() bool {
+	return s>>uint(len(` + membersVar + `)) == 0
+}
+
+// Has determines whether every member in flags is set
+func (s ` + typeName + `) Has(flags ` + typeName + `) bool {
+	return s&flags == flags
+}
+
+// String returns the comma-separated members that are set
+func (s ` + typeName + `) String() string {
+	var set []string
+	for i, member := range ` + membersVar + ` {
+		if s&(1<<uint(i)) != 0 {
+			set = append(set, member)
+		}
+	}
+	return strings.Join(set, ",")
+}
+
+// Scan implements the sql.Scanner interface
+func (s *` + typeName + `) Scan(src interface{}) error {
+	var str string
+	switch v := src.(type) {
+	case []byte:
+		str = string(v)
+	case string:
+		str = v
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into ` + typeName + `", src)
+	}
+
+	*s = 0
+	if str == "" {
+		return nil
+	}
+Members:
+	for _, value := range strings.Split(str, ",") {
+		for i, member := range ` + membersVar + ` {
+			if member == value {
+				*s |= 1 << uint(i)
+				continue Members
+			}
+		}
+		return fmt.Errorf("invalid member %q for ` + typeName + `", value)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (s ` + typeName + `) Value() (driver.Value, error) {
+	return s.String(), nil
+}`
+}
@@ -0,0 +1,21 @@
+package gostruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnumIdentifiers(t *testing.T) {
+	for _, test := range []struct {
+		values []string
+		want   []string
+	}{
+		{[]string{"active", "in-active", ""}, []string{"Active", "InActive", "Empty"}},
+		{[]string{"élevé", "ñ b"}, []string{"Élevé", "ÑB"}},
+		{[]string{"ab", "a b", "AB", "AB2"}, []string{"Ab", "AB", "AB2", "AB23"}},
+	} {
+		if got := enumIdentifiers(test.values); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.values, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package gostruct
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// uniqueIndex is a unique index of a table other than the primary key
+type uniqueIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// fakeColumn is a column of the table along with the type of its model field
+type fakeColumn struct {
+	Object tableObj
+	Type   string
+	// Imports maps the import paths the type depends on to their alias
+	Imports map[string]string
+}
+
+// getUniqueIndexes returns every unique index defined on a table, except for the primary key
+func getUniqueIndexes(con *sql.DB, database, table string) ([]uniqueIndex, error) {
+	rows, err := con.Query("SELECT index_name, column_name FROM information_schema.statistics WHERE table_schema = ? AND table_name = ? AND non_unique = 0 AND index_name != 'PRIMARY' ORDER BY index_name, seq_in_index", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []uniqueIndex
+	for rows.Next() {
+		var name, column string
+		err = rows.Scan(&name, &column)
+		if err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].Name != name {
+			indexes = append(indexes, uniqueIndex{Name: name})
+		}
+		indexes[len(indexes)-1].Columns = append(indexes[len(indexes)-1].Columns, column)
+	}
+
+	return indexes, rows.Err()
+}
+
+// buildFake builds the {table}_fake.go file with an in-memory implementation of the repository interface.
+// keyParam & keyArgs are the parameter & the key values of ReadByKey, features are the features of the table
+func (g Gostruct) buildFake(table string, features TableFeatures, columns []fakeColumn, primaryKeys []string, keyParam, keyArgs string, indexes []uniqueIndex) error {
+	tableNaming := uppercaseFirst(table)
+	dir := g.modelDir + "/" + tableNaming + "/"
+
+	imports := map[string]string{
+		"database/sql":             "",
+		g.dbDir:                    "db",
+		"github.com/pkg/errors":    "",
+		"golang.org/x/net/context": "",
+	}
+
+	var keyValues []string
+	for _, pk := range primaryKeys {
+		keyValues = append(keyValues, "obj."+uppercaseFirst(pk))
+	}
+
+	// ReadByKey takes the key columns as their model types, which may need an import
+	for _, c := range columns {
+		if inArray(c.Object.Name, primaryKeys) {
+			for path, alias := range c.Imports {
+				imports[path] = alias
+			}
+		}
+	}
+
+	var columnValues, autoIncrement, deepCopy string
+	for _, c := range columns {
+		field := "obj." + uppercaseFirst(c.Object.Name)
+		columnValues += "\n\t\t\t\"" + strings.ToLower(c.Object.Name) + "\": func(obj *" + tableNaming + ") interface{} { return " + field + " },"
+
+		// pointers & slices are copied as well, so the copy shares no memory with the record
+		copyField := "c." + uppercaseFirst(c.Object.Name)
+		switch {
+		case strings.HasPrefix(c.Type, "*"):
+			deepCopy += `
+			if ` + field + ` != nil {
+				v := *` + field + `
+				` + copyField + ` = &v
+			}`
+		case strings.HasPrefix(c.Type, "[]") || c.Type == "json.RawMessage":
+			deepCopy += `
+			if ` + field + ` != nil {
+				` + copyField + ` = append(` + field + `[:0:0], ` + field + `...)
+			}`
+		}
+
+		if strings.Contains(c.Object.Extra.String, "auto_increment") {
+			switch c.Type {
+			case "int64", "int16", "uint8", "uint16", "uint32", "uint64":
+				autoIncrement = `
+		AutoIncrement: func(obj *` + tableNaming + `, next int64) int64 {
+			if ` + field + ` == 0 {
+				` + field + ` = ` + c.Type + `(next)
+			}
+			return int64(` + field + `)
+		},`
+			}
+		}
+	}
+
+	var uniqueFuncs string
+	for _, index := range indexes {
+		var nullChecks, values []string
+		for _, column := range index.Columns {
+			for _, c := range columns {
+				if c.Object.Name != column {
+					continue
+				}
+				field := "obj." + uppercaseFirst(column)
+				switch {
+				case strings.HasPrefix(c.Type, "*"):
+					nullChecks = append(nullChecks, field+" == nil")
+					field = "*" + field
+				case c.Object.IsNullable == "YES" && (c.Type == "[]byte" || c.Type == "json.RawMessage"):
+					nullChecks = append(nullChecks, field+" == nil")
+				}
+				values = append(values, field)
+			}
+		}
+
+		uniqueFuncs += "\n\t\t\t\"" + index.Name + "\": func(obj *" + tableNaming + ") []interface{} {"
+		if len(nullChecks) > 0 {
+			uniqueFuncs += `
+				// NULL never conflicts
+				if ` + strings.Join(nullChecks, " || ") + ` {
+					return nil
+				}`
+		}
+		uniqueFuncs += `
+				return []interface{}{` + strings.Join(values, ", ") + `}
+			},`
+	}
+
+	var featureFuncs string
+	if features.SoftDelete != "" {
+		field := "obj." + uppercaseFirst(features.SoftDelete)
+		imports["time"] = ""
+		featureFuncs += `
+		Deleted: func(obj *` + tableNaming + `) bool {
+			return ` + field + ` != nil
+		},
+		SoftDelete: func(obj *` + tableNaming + `) {
+			now := time.Now()
+			` + field + ` = &now
+		},`
+	}
+	if features.Version != "" {
+		field := "obj." + uppercaseFirst(features.Version)
+		featureFuncs += `
+		Version: func(obj *` + tableNaming + `, increment bool) int64 {
+			if increment {
+				` + field + `++
+			}
+			return int64(` + field + `)
+		},`
+	}
+
+	contents := `package ` + tableNaming + importBlock(imports) + `
+
+// Fake is an in-memory ` + tableNaming + `Repository for unit tests. It enforces the primary key & unique indexes of
+// the ` + table + ` table, assigns auto_increment values, validates records the way Save does and honors
+// db.QueryOptions. Custom queries are answered by QueryFunc & ExecFunc
+type Fake struct {
+	// QueryFunc answers ReadByQuery, ReadOneByQuery & StreamByQuery
+	QueryFunc func(ctx context.Context, query string, args ...interface{}) ([]*` + tableNaming + `, error)
+	// ExecFunc answers Exec
+	ExecFunc func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+
+	table *db.FakeTable[*` + tableNaming + `]
+}
+
+var _ ` + tableNaming + `Repository = (*Fake)(nil)
+
+// NewFake returns an empty fake ` + table + ` table
+func NewFake() *Fake {
+	return &Fake{table: &db.FakeTable[*` + tableNaming + `]{
+		Name: "` + table + `",`
+	if len(primaryKeys) > 0 {
+		contents += `
+		Key: func(obj *` + tableNaming + `) []interface{} {
+			return []interface{}{` + strings.Join(keyValues, ", ") + `}
+		},`
+	}
+	if uniqueFuncs != "" {
+		contents += `
+		Unique: map[string]func(*` + tableNaming + `) []interface{}{` + uniqueFuncs + `
+		},`
+	}
+	contents += `
+		Columns: map[string]func(*` + tableNaming + `) interface{}{` + columnValues + `
+		},
+		Copy: func(obj *` + tableNaming + `) *` + tableNaming + ` {
+			c := *obj` + deepCopy + `
+			return &c
+		},` + autoIncrement + featureFuncs + `
+	}}
+}
+
+// Insert saves records into the fake, e.g. to seed it before a test
+func (f *Fake) Insert(objects ...*` + tableNaming + `) error {
+	for _, obj := range objects {
+		_, err := f.table.Save(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}`
+
+	if len(primaryKeys) > 0 {
+		contents += `
+
+// ReadByKey returns a single pointer to a(n) ` + tableNaming + `
+func (f *Fake) ReadByKey(ctx context.Context, ` + keyParam + `) (*` + tableNaming + `, error) {
+	return f.table.Get(` + keyArgs + `)
+}`
+	}
+
+	if len(primaryKeys) > 0 && !features.ReadOnly {
+		saveDoc := "// Save inserts the record, or replaces the record with the same primary key"
+		if features.Version != "" {
+			saveDoc += " when its version still\n// matches, incrementing it"
+		}
+		deleteDoc := "// Delete removes the record with the same primary key"
+		if features.SoftDelete != "" {
+			deleteDoc = "// Delete marks the record with the same primary key as deleted"
+		}
+
+		contents += `
+
+` + saveDoc + `
+func (f *Fake) Save(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error) {
+	return f.table.Save(obj)
+}
+
+` + deleteDoc + `
+func (f *Fake) Delete(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error) {
+	return f.table.Delete(obj)
+}`
+	}
+
+	contents += `
+
+// ReadAll returns all records, ordered & limited by the options
+func (f *Fake) ReadAll(ctx context.Context, options ...db.QueryOptions) ([]*` + tableNaming + `, error) {
+	return f.table.All(options...)
+}
+
+// ReadByQuery returns the records of QueryFunc
+func (f *Fake) ReadByQuery(ctx context.Context, query string, args ...interface{}) ([]*` + tableNaming + `, error) {
+	if f.QueryFunc == nil {
+		return nil, errors.Wrap(db.ErrUnsupported, query)
+	}
+	return f.QueryFunc(ctx, query, args...)
+}
+
+// ReadOneByQuery returns the first record of QueryFunc
+func (f *Fake) ReadOneByQuery(ctx context.Context, query string, args ...interface{}) (*` + tableNaming + `, error) {
+	objects, err := f.ReadByQuery(ctx, query, args...)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, db.ErrNotFound
+	}
+	return objects[0], nil
+}
+
+// StreamByQuery calls fn for every record of QueryFunc
+func (f *Fake) StreamByQuery(ctx context.Context, query string, fn func(*` + tableNaming + `) error, args ...interface{}) error {
+	objects, err := f.ReadByQuery(ctx, query, args...)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		return err
+	}
+	for _, obj := range objects {
+		err = fn(obj)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Exec returns the result of ExecFunc
+func (f *Fake) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if f.ExecFunc == nil {
+		return nil, errors.Wrap(db.ErrUnsupported, query)
+	}
+	return f.ExecFunc(ctx, query, args...)
+}
+`
+
+	fakeFilePath := dir + tableNaming + "_fake.go"
+	return g.writeGoFile(fakeFilePath, contents, true)
+}
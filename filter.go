@@ -0,0 +1,141 @@
+package gostruct
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultSkipMarker leaves out the tables whose comment contains it when no other marker is configured
+const defaultSkipMarker = "gostruct:skip"
+
+// selectTables returns the names of the tables that are selected by the include & exclude patterns, the table
+// types and the skip marker
+func (g Gostruct) selectTables(tables []table) []string {
+	marker := g.SkipMarker
+	if marker == "" {
+		marker = defaultSkipMarker
+	}
+
+	var selected []string
+	for _, tbl := range tables {
+		if !g.includeTable(tbl.Name) || !g.includeType(tbl.Type) || strings.Contains(tbl.Comment, marker) {
+			continue
+		}
+		selected = append(selected, tbl.Name)
+	}
+
+	return selected
+}
+
+// includeTable determines whether a table is selected by the include & exclude patterns
+func (g Gostruct) includeTable(table string) bool {
+	included := len(g.Include) == 0
+	for _, pattern := range g.Include {
+		if g.matchPattern(pattern, table) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range g.Exclude {
+		if g.matchPattern(pattern, table) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// includeType determines whether a table type is selected. Every type is selected when no types are set
+func (g Gostruct) includeType(tableType string) bool {
+	if len(g.TableTypes) == 0 {
+		return true
+	}
+
+	for _, t := range g.TableTypes {
+		if strings.EqualFold(t, tableType) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern determines whether a table name matches a glob pattern, or a regular expression when the
+// pattern is wrapped in slashes. The regular expressions are compiled by checkPatterns, invalid patterns don't
+// match
+func (g Gostruct) matchPattern(pattern, name string) bool {
+	if _, ok := regexPattern(pattern); ok {
+		re, ok := g.patterns[pattern]
+		return ok && re.MatchString(name)
+	}
+
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// regexPattern returns the regular expression of a pattern wrapped in slashes
+func regexPattern(pattern string) (string, bool) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return pattern[1 : len(pattern)-1], true
+	}
+	return "", false
+}
+
+// checkPatterns makes sure the include & exclude patterns are valid and compiles the regular expressions
+func (g *Gostruct) checkPatterns() error {
+	g.patterns = map[string]*regexp.Regexp{}
+	for _, pattern := range append(append([]string{}, g.Include...), g.Exclude...) {
+		var err error
+		if expr, ok := regexPattern(pattern); ok {
+			g.patterns[pattern], err = regexp.Compile(expr)
+		} else {
+			_, err = filepath.Match(pattern, "")
+		}
+		if err != nil {
+			return errors.New("invalid table pattern " + pattern + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// splitList splits a comma separated flag value, trimming the spaces around every item
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitPatterns splits a comma separated list of patterns like splitList, except for the commas of the regular
+// expressions wrapped in slashes, e.g. /^log_[0-9]{1,3}$/
+func splitPatterns(s string) []string {
+	var patterns []string
+	pattern, open := "", false
+	for _, item := range strings.Split(s, ",") {
+		if open {
+			pattern += "," + item
+		} else {
+			pattern = strings.TrimSpace(item)
+		}
+
+		trimmed := strings.TrimSpace(pattern)
+		open = strings.HasPrefix(trimmed, "/") && (len(trimmed) == 1 || !strings.HasSuffix(trimmed, "/"))
+		if !open && trimmed != "" {
+			patterns = append(patterns, trimmed)
+		}
+	}
+	if open {
+		patterns = append(patterns, strings.TrimSpace(pattern))
+	}
+	return patterns
+}
@@ -0,0 +1,61 @@
+package gostruct
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectTables(t *testing.T) {
+	tables := []table{
+		{Name: "user", Type: "BASE TABLE"},
+		{Name: "user_archive", Type: "BASE TABLE"},
+		{Name: "role", Type: "BASE TABLE", Comment: "roles gostruct:skip"},
+		{Name: "schema_migrations", Type: "BASE TABLE"},
+		{Name: "tmp_123", Type: "BASE TABLE"},
+		{Name: "active_users", Type: "VIEW"},
+	}
+
+	for _, test := range []struct {
+		name string
+		g    Gostruct
+		want []string
+	}{
+		{"default", Gostruct{}, []string{"user", "user_archive", "schema_migrations", "tmp_123", "active_users"}},
+		{"globs", Gostruct{Include: []string{"user*", "active_*"}, Exclude: []string{"*_archive"}}, []string{"user", "active_users"}},
+		{"regexes", Gostruct{Exclude: []string{"/^tmp_[0-9]+$/", "schema_migrations", "/archive/"}}, []string{"user", "active_users"}},
+		{"types", Gostruct{TableTypes: []string{"base table"}}, []string{"user", "user_archive", "schema_migrations", "tmp_123"}},
+		{"marker", Gostruct{SkipMarker: "roles", Include: []string{"role", "user"}}, []string{"user"}},
+	} {
+		err := test.g.checkPatterns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := test.g.selectTables(tables); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	if err := (&Gostruct{Exclude: []string{"/tmp_(/"}}).checkPatterns(); err == nil {
+		t.Error("invalid regular expression accepted")
+	}
+	if err := (&Gostruct{Include: []string{"user["}}).checkPatterns(); err == nil {
+		t.Error("invalid glob pattern accepted")
+	}
+}
+
+func TestSplitPatterns(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want []string
+	}{
+		{"user*, role,", []string{"user*", "role"}},
+		{`/^log_\d{1,3}$/, tmp_*`, []string{`/^log_\d{1,3}$/`, "tmp_*"}},
+		{`role, /^(a|b),c$/,/x/`, []string{"role", `/^(a|b),c$/`, "/x/"}},
+		{"/, user", []string{"/, user"}},
+		{"", nil},
+	} {
+		if got := splitPatterns(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.s, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package gostruct
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// writeGoFile formats generated Go source, removes its unused imports and writes it to path. Existing files
+// are only replaced when overwrite is set. Nothing is written when the source doesn't parse, or in dry-run &
+// diff mode, which report the change instead
+func (g Gostruct) writeGoFile(path, contents string, overwrite bool) error {
+	src, err := formatSource(path, contents)
+	if err != nil {
+		return err
+	}
+
+	if g.preview() {
+		return g.reportChange(path, src, overwrite)
+	}
+	if exists(path) && !overwrite {
+		return nil
+	}
+
+	return writeFile(path, src, overwrite)
+}
+
+// formatSource formats generated Go source the way gofmt does & removes its unused imports the way
+// goimports does. filename is only used in error messages
+func formatSource(filename, contents string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, contents, parser.ParseComments)
+	if err != nil {
+		return "", sourceError(contents, err)
+	}
+
+	pruneImports(fset, file)
+
+	var buf bytes.Buffer
+	err = format.Node(&buf, fset, file)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// sourceError points a parse error of generated source at the offending line
+func sourceError(contents string, err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok || len(list) == 0 {
+		return err
+	}
+
+	first := list[0]
+	lines := strings.Split(contents, "\n")
+	if first.Pos.Line < 1 || first.Pos.Line > len(lines) {
+		return fmt.Errorf("generated code does not parse: %v", first)
+	}
+
+	// the previous line is included, since the error is often only detected at the start of the next line
+	var context string
+	for line := first.Pos.Line - 1; line <= first.Pos.Line; line++ {
+		if line >= 1 {
+			context += fmt.Sprintf("\n\t%d: %s", line, strings.TrimSpace(lines[line-1]))
+		}
+	}
+
+	return fmt.Errorf("generated code does not parse: %v%s", first, context)
+}
+
+// pruneImports removes the imports that aren't referred to by any selector. Blank & dot imports are kept, as
+// are imports whose package name can't be told from the path
+func pruneImports(fset *token.FileSet, file *ast.File) {
+	used := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok {
+				used[x.Name] = true
+			}
+		}
+		return true
+	})
+
+	for _, imp := range append([]*ast.ImportSpec(nil), file.Imports...) {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importName(path)
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if name == "" || name == "_" || name == "." || used[name] {
+			continue
+		}
+		deleteImport(fset, file, imp)
+	}
+}
+
+// deleteImport removes a single import from the file
+func deleteImport(fset *token.FileSet, file *ast.File, imp *ast.ImportSpec) {
+	for i, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for j, spec := range gen.Specs {
+			if spec != imp {
+				continue
+			}
+
+			// pull the following lines up, so the import doesn't leave an empty line behind
+			tokenFile := fset.File(imp.Pos())
+			if line := fset.Position(imp.Pos()).Line; gen.Lparen.IsValid() && line < tokenFile.LineCount() {
+				tokenFile.MergeLine(line)
+			}
+
+			gen.Specs = append(gen.Specs[:j], gen.Specs[j+1:]...)
+			if len(gen.Specs) == 0 {
+				file.Decls = append(file.Decls[:i], file.Decls[i+1:]...)
+			}
+			break
+		}
+	}
+
+	for i, spec := range file.Imports {
+		if spec == imp {
+			file.Imports = append(file.Imports[:i], file.Imports[i+1:]...)
+			break
+		}
+	}
+}
+
+// importName returns the package name of an import path, following the conventions goimports relies on:
+// major version elements, .vN suffixes & go- prefixes aren't part of the name. An empty string is returned
+// when the name can't be told from the path
+func importName(path string) string {
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+
+	if !token.IsIdentifier(name) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package gostruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSourcePrunesImports(t *testing.T) {
+	src := `package p
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	_ "time"
+
+	db "connection"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+func f() { fmt.Println(db.Get, yaml.Marshal) }
+`
+	want := `package p
+
+import (
+	"fmt"
+	_ "time"
+
+	db "connection"
+	"gopkg.in/yaml.v2"
+)
+
+func f() { fmt.Println(db.Get, yaml.Marshal) }
+`
+
+	got, err := formatSource("p.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatSourceReportsLine(t *testing.T) {
+	src := "package p\n\nfunc f() {\n\treturn 1 +\n}\n"
+
+	_, err := formatSource("models/P/P_base.go", src)
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	for _, want := range []string{"models/P/P_base.go:5:1", "4: return 1 +"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't contain %q", err, want)
+		}
+	}
+}
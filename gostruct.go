@@ -7,7 +7,9 @@ User_base.go - CRUD operations and common ReadBy functions. It also validates an
 
 User_extended.go - Custom functions & methods
 
-User_test.go - Serves as a base for your unit testing
+User_fake.go - In-memory implementation of the repository interface for unit tests
+
+User_base_test.go - Round trip & enum validation tests, run against the fake or the database in GOSTRUCT_TEST_DSN
 
 examples_test.go - Includes auto-generated example methods based on the auto-generated methods in the CRUX file
 
@@ -15,12 +17,15 @@ It will also generate a connection package to share connection(s) to prevent mul
 
 Dependencies:
 
-        go get github.com/go-sql-driver/mysql
-        go get github.com/pkg/errors
+	go get github.com/go-sql-driver/mysql
+	go get github.com/pkg/errors
+	go get github.com/shopspring/decimal
+
+The generated packages use generics and need Go 1.18 or later.
 
 Installation:
 
-        go get github.com/jrkt/gostruct
+	go get github.com/jrkt/gostruct
 
 Create a generate.go file with the following contents (including your db username/password):
 
@@ -42,7 +47,12 @@ Create a generate.go file with the following contents (including your db usernam
 
 Then, run:
 
-        go run generate.go -tables User -db main -host localhost
+	go run generate.go -tables User -db main -host localhost
+
+Settings can also be kept in a gostruct.yaml file in the working directory or one of its parents, which the
+flags override. It holds the connection settings by database, the tables to generate, the output directories,
+the naming options, the type overrides and the features of every table, such as soft delete, a version column
+for optimistic locking, or read-only packages.
 */
 package gostruct
 
@@ -53,6 +63,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -71,29 +84,70 @@ type Gostruct struct {
 	modelDir  string
 	dbDir     string
 	NameFuncs bool
-	add       chan int
-	totalChan chan int
-	errorChan chan error
-	processed int
-	errored   int
-	errors    []error
-	total     int
+	// DryRun lists the files that would be created, modified or left untouched instead of writing them
+	DryRun bool
+	// Diff prints the unified diff of every file that would change instead of writing it
+	Diff bool
+	// Force regenerates tables whose hash in the manifest didn't change
+	Force bool
+	// TypeOverrides maps columns or database types to custom Go types
+	TypeOverrides []TypeOverride
+	// Include & Exclude select the tables RunAll generates by glob pattern, or by regular expression for
+	// patterns wrapped in slashes, e.g. /^tmp_[0-9]+$/
+	Include []string
+	Exclude []string
+	// TableTypes limits the tables RunAll generates to these types, e.g. BASE TABLE or VIEW
+	TableTypes []string
+	// SkipMarker leaves out the tables whose comment contains it. It defaults to gostruct:skip
+	SkipMarker string
+	// Features holds the feature toggles by table
+	Features map[string]TableFeatures
+	// Connections holds the connection settings the connection package uses by database
+	Connections map[string]ConnectionConfig
+	// Procs generates the procs package with typed wrappers for the stored procedures & functions
+	Procs bool
+	// ProcResults holds the columns of the result set of every stored procedure that returns rows
+	ProcResults map[string][]ResultColumn
+	procsDir    string
+	selected    map[string]bool
+	patterns    map[string]*regexp.Regexp
+	add         chan int
+	totalChan   chan int
+	errorChan   chan error
+	output      chan string
+	hashChan    chan tableHash
+	hashes      map[string]string
+	generated   map[string]string
+	processed   int
+	errored     int
+	errors      []error
+	total       int
 }
 
 // tableObj is the result set returned from the MySQL information_schema that
 // contains all data for a specific table
 type tableObj struct {
-	Name       string
-	IsNullable string
-	Key        string
-	DataType   string
-	ColumnType string
-	Default    sql.NullString
-	Extra      sql.NullString
+	Name       string         `json:"name"`
+	IsNullable string         `json:"is_nullable"`
+	Key        string         `json:"key"`
+	DataType   string         `json:"data_type"`
+	ColumnType string         `json:"column_type"`
+	Default    sql.NullString `json:"default"`
+	Extra      sql.NullString `json:"extra"`
+	// CharMaxLength is the maximum length of a string column in characters, or bytes for binary columns
+	CharMaxLength    sql.NullInt64 `json:"character_maximum_length"`
+	NumericPrecision sql.NullInt64 `json:"numeric_precision"`
+	NumericScale     sql.NullInt64 `json:"numeric_scale"`
+	// IsBool is set for tinyint & smallint columns that only hold 0, 1 or NULL
+	IsBool bool `json:"is_bool,omitempty"`
 }
 
+// table is a table of the database as listed in the information_schema
 type table struct {
 	Name string
+	// Type is BASE TABLE or VIEW
+	Type    string
+	Comment string
 }
 
 type usedColumn struct {
@@ -110,6 +164,9 @@ var (
 	wg     sync.WaitGroup
 )
 
+// ErrStale is returned by Generate in verify mode when a generated model differs from the schema
+var ErrStale = errors.New("the generated models are stale")
+
 // initialize global GOPATH
 func init() {
 	GOPATH = os.Getenv("GOPATH")
@@ -118,8 +175,10 @@ func init() {
 	}
 }
 
-// Generate serves as the main method to build package
+// Generate serves as the main method to build package. When the first argument is "verify", the generated
+// models are compared with the schema instead, and ErrStale is returned if they are stale
 func (g *Gostruct) Generate() error {
+	verify := len(os.Args) > 1 && os.Args[1] == "verify"
 
 	tbls := flag.String("tables", "", "Comma separated list of tables")
 	db := flag.String("db", "", "Database")
@@ -129,14 +188,58 @@ func (g *Gostruct) Generate() error {
 	nameFuncs := flag.Bool("nameFuncs", false, "Whether to include the struct name in the function signature")
 	dbDir := flag.String("dbDir", "connection", "directory where connection package should be stored")
 	modelDir := flag.String("modelDir", "", "directory where models should live")
-	flag.Parse()
+	procsDir := flag.String("procsDir", "", "directory where the procs package should live (default {modelDir}/procs)")
+	dryRun := flag.Bool("dry-run", false, "List the files that would be created, modified, deleted or left untouched without writing them")
+	diff := flag.Bool("diff", false, "Print the diffs of the files that would change without writing them")
+	schemaFile := flag.String("schema", "", "verify: JSON schema snapshot to compare the models with, instead of the database")
+	saveSchemaFile := flag.String("save-schema", "", "verify: file to write the JSON schema snapshot to")
+	force := flag.Bool("force", false, "Regenerate tables that didn't change since they were last generated")
+	include := flag.String("include", "", "Comma separated list of glob patterns, or /regular expressions/, of the tables to generate")
+	exclude := flag.String("exclude", "", "Comma separated list of glob patterns, or /regular expressions/, of the tables to leave out")
+	tableTypes := flag.String("types", "", "Comma separated list of the table types to generate, e.g. BASE TABLE or VIEW")
+	procs := flag.Bool("procs", false, "Generate the procs package for the stored procedures & functions")
+	configPath := flag.String("config", "", "Configuration file (default "+configFile+" in the working directory or a parent directory)")
+	if verify {
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 
-	err := g.buildConnectionPkg()
-	if err != nil {
-		panic(err)
+	// flags that were passed override the configuration file
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	var config Config
+	if *configPath == "" {
+		var err error
+		*configPath, err = findConfig()
+		if err != nil {
+			return err
+		}
+	}
+	if *configPath != "" {
+		var err error
+		config, err = loadConfig(*configPath)
+		if err != nil {
+			return errors.New("config error: " + *configPath + ": " + err.Error())
+		}
+		g.applyConfig(config)
+	}
+
+	// nothing is written in dry-run & diff mode, including the connection package
+	if set["dry-run"] {
+		g.DryRun = *dryRun
+	}
+	if set["diff"] {
+		g.Diff = *diff
 	}
 
 	g.dbDir = GOPATH + "/src/connection"
+	if config.Output.DBDir != "" && !set["dbDir"] {
+		*dbDir = config.Output.DBDir
+	}
 	if *dbDir != "" {
 		g.dbDir = *dbDir
 		if last := len(g.dbDir) - 1; last >= 0 && g.dbDir[last] == '/' {
@@ -148,6 +251,9 @@ func (g *Gostruct) Generate() error {
 	g.modelDir = strings.Replace(g.dbDir, GOPATH+"/src/", "", 1)
 
 	g.modelDir = g.dbDir + "/models"
+	if config.Output.ModelDir != "" && !set["modelDir"] {
+		*modelDir = config.Output.ModelDir
+	}
 	if *modelDir != "" {
 		g.modelDir = *modelDir
 		if last := len(g.modelDir) - 1; last >= 0 && g.modelDir[last] == '/' {
@@ -155,16 +261,92 @@ func (g *Gostruct) Generate() error {
 		}
 	}
 
-	g.Database = *db
-	g.Host = *host
-	g.NameFuncs = *nameFuncs
-	g.Port = *port
+	if set["db"] || g.Database == "" {
+		g.Database = *db
+	}
+	g.applyConnection()
+	if set["host"] {
+		g.Host = *host
+	}
+	if set["port"] {
+		g.Port = *port
+	}
+	if g.Port == "" {
+		g.Port = *port
+	}
+	if set["nameFuncs"] {
+		g.NameFuncs = *nameFuncs
+	}
+	if set["procs"] {
+		g.Procs = *procs
+	}
+	g.procsDir = g.modelDir + "/procs"
+	if config.Output.ProcsDir != "" && !set["procsDir"] {
+		*procsDir = config.Output.ProcsDir
+	}
+	if *procsDir != "" {
+		g.procsDir = strings.TrimSuffix(*procsDir, "/")
+	}
+	if set["force"] {
+		g.Force = *force
+	}
+	if set["include"] {
+		g.Include = splitPatterns(*include)
+	}
+	if set["exclude"] {
+		g.Exclude = splitPatterns(*exclude)
+	}
+	if set["types"] {
+		g.TableTypes = splitList(*tableTypes)
+	}
+
+	err := g.checkPatterns()
+	if err != nil {
+		return err
+	}
+
+	// tables are selected by the include patterns of the configuration file when no tables are passed. Patterns
+	// set in Go code or passed as a flag still need the tables or all flag
+	if *tbls == "" && len(config.Tables.Include) > 0 && !set["include"] {
+		*all = true
+	}
+
+	if !verify {
+		err = g.buildConnectionPkg()
+		if err != nil {
+			return err
+		}
+	}
+
+	if verify {
+		var tables []string
+		if *tbls != "" {
+			tables = strings.Split(strings.Replace(*tbls, " ", "", -1), ",")
+		}
+		upToDate, err := g.verify(tables, *schemaFile, *saveSchemaFile)
+		if err != nil {
+			return errors.New("verify error: " + err.Error())
+		}
+		if !upToDate {
+			return ErrStale
+		}
+		return nil
+	}
 
 	g.add = make(chan int, 1)
 	g.errorChan = make(chan error, 1)
 	g.totalChan = make(chan int, 1)
+	g.output = make(chan string)
+	g.hashChan = make(chan tableHash)
 	work := make(chan string, 1)
 
+	g.hashes, err = g.loadManifest()
+	if err != nil {
+		return err
+	}
+	g.generated = map[string]string{}
+	g.selected = map[string]bool{}
+
 	go g.handler()
 
 	for i := 0; i < 50; i++ {
@@ -180,12 +362,17 @@ func (g *Gostruct) Generate() error {
 			return err
 		}
 	} else {
-		if (*tbls == "" && !*all) || *db == "" || *host == "" {
+		if (*tbls == "" && !*all && !g.Procs) || g.Database == "" || g.Host == "" {
 			return errors.New("You must include the 'table', 'database', and 'host' flag")
 		}
-		t := strings.Replace(*tbls, " ", "", -1)
-		tables := strings.Split(t, ",")
+		var tables []string
+		if *tbls != "" {
+			tables = strings.Split(strings.Replace(*tbls, " ", "", -1), ",")
+		}
 		g.total = len(tables)
+		for _, tbl := range tables {
+			g.selected[tbl] = true
+		}
 		for _, tbl := range tables {
 			wg.Add(1)
 			work <- tbl
@@ -195,6 +382,25 @@ func (g *Gostruct) Generate() error {
 	log.Println("Waiting for goroutines to finish work...")
 	wg.Wait()
 
+	if g.Procs {
+		log.Println("Building package: procs")
+		err = g.RunProcs()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !g.preview() {
+		err = g.saveManifest()
+		if err != nil {
+			return err
+		}
+	}
+
+	// the tables that failed are listed in the results
+	if g.errored > 0 {
+		return errors.New(strconv.Itoa(g.errored) + " of " + strconv.Itoa(g.processed) + " tables failed")
+	}
 	return nil
 }
 
@@ -208,9 +414,16 @@ func (g *Gostruct) handler() {
 			showProgress(*g)
 		case cnt := <-g.totalChan:
 			g.total += cnt
+		case h := <-g.hashChan:
+			g.generated[h.Table] = h.Hash
+		case out := <-g.output:
+			fmt.Print(out)
 		case err := <-g.errorChan:
+			// a table that failed is done as well, so a single failure can't block the run
+			g.processed++
 			g.errored++
 			g.errors = append(g.errors, err)
+			wg.Done()
 			println("ERROR:", err.Error())
 		}
 	}
@@ -223,33 +436,28 @@ func (g Gostruct) worker(work <-chan string) {
 	}
 }
 
-// RunAll generates packages for all tables in a specific database and host
+// RunAll generates packages for all tables in a specific database and host that are selected by the include &
+// exclude patterns, the table types and the skip marker
 func (g *Gostruct) RunAll(work chan<- string) error {
 	connection, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", g.Username, g.Password, g.Host, g.Port, g.Database))
 	if err != nil {
 		return err
 	}
 
-	type Count struct {
-		cnt int
-	}
-	var count Count
-	err = connection.QueryRow("SELECT COUNT(DISTINCT(TABLE_NAME)) FROM `information_schema`.`COLUMNS` WHERE `TABLE_SCHEMA` LIKE ?", g.Database).Scan(&count.cnt)
+	tables, err := getTables(connection, g.Database)
 	if err != nil {
 		return err
 	}
-	g.totalChan <- count.cnt
 
-	rows, err := connection.Query("SELECT DISTINCT(TABLE_NAME) FROM `information_schema`.`COLUMNS` WHERE `TABLE_SCHEMA` LIKE ?", g.Database)
-	if err != nil {
-		return err
+	selected := g.selectTables(tables)
+	g.totalChan <- len(selected)
+	for _, table := range selected {
+		g.selected[table] = true
 	}
 
-	for rows.Next() {
+	for _, table := range selected {
 		wg.Add(1)
-		var tbl table
-		rows.Scan(&tbl.Name)
-		work <- tbl.Name
+		work <- table
 	}
 
 	return nil
@@ -258,7 +466,7 @@ func (g *Gostruct) RunAll(work chan<- string) error {
 // Run handles the run for a single table
 func (g Gostruct) Run(table string) {
 	// make sure models dir exists
-	if !exists(g.modelDir) {
+	if !exists(g.modelDir) && !g.preview() {
 		err := createDirectory(g.modelDir)
 		if err != nil {
 			g.errorChan <- err
@@ -270,99 +478,150 @@ func (g Gostruct) Run(table string) {
 
 	con, err := getConnection(g)
 	if err != nil {
-		g.errorChan <- err
+		g.errorChan <- errors.New(table + ": " + err.Error())
 		return
 	}
 
-	rows1, err := con.Query("SELECT column_name, is_nullable, column_key, data_type, column_type, column_default, extra FROM information_schema.columns WHERE table_name = ? AND table_schema = ?", table, g.Database)
+	schema, err := getTableSchema(con, g.Database, table)
 	if err != nil {
-		g.errorChan <- err
+		g.errorChan <- errors.New(table + ": " + err.Error())
 		return
 	}
 
-	var object tableObj
-	var objects []tableObj
-	var columns []string
-
-	tableNaming := uppercaseFirst(table)
+	g.runTable(table, schema)
+}
 
-	cntPK := 0
-	for rows1.Next() {
-		rows1.Scan(&object.Name, &object.IsNullable, &object.Key, &object.DataType, &object.ColumnType, &object.Default, &object.Extra)
-		objects = append(objects, object)
-		if object.Key == "PRI" {
-			cntPK++
-		}
-		if cntPK > 1 && object.Key == "PRI" {
-			continue
-		}
-		columns = append(columns, object.Name)
+// runTable builds the package of a single table unless it didn't change since it was last generated. Every
+// table reports exactly once, through g.add when it is done or through g.errorChan when it failed
+func (g Gostruct) runTable(table string, schema tableSchema) {
+	// tables that didn't change since they were last generated are skipped, except in dry-run & diff mode,
+	// which compare the generated files with the files on disk
+	hash := g.tableHash(table, schema)
+	if !g.preview() && g.upToDate(table, hash) {
+		log.Println("Skipping unchanged package:", table)
+		g.add <- 1
+		return
 	}
-	defer rows1.Close()
 
-	if len(objects) == 0 {
-		g.errorChan <- errors.New("No results for table: " + table)
+	err := g.buildTable(table, schema)
+	if err != nil {
+		g.errorChan <- errors.New(table + ": " + err.Error())
 		return
 	}
 
+	if !g.preview() {
+		g.hashChan <- tableHash{Table: table, Hash: hash}
+	}
+
+	g.add <- 1
+}
+
+// buildTable builds the package of a single table from the metadata read from the information_schema
+func (g Gostruct) buildTable(table string, schema tableSchema) error {
+	err := g.checkNames(table, schema)
+	if err != nil {
+		return err
+	}
+
 	// create directory if needed
-	dir := g.modelDir + "/" + tableNaming + "/"
-	if !exists(dir) {
-		err := os.Mkdir(dir, 0777)
+	dir := g.modelDir + "/" + uppercaseFirst(table) + "/"
+	if !exists(dir) && !g.preview() {
+		err = os.Mkdir(dir, 0777)
 		if err != nil {
-			g.errorChan <- err
-			return
+			return err
 		}
 
 		// give new directory full permissions
 		err = os.Chmod(dir, 0777)
 		if err != nil {
-			g.errorChan <- err
-			return
+			return err
 		}
 	}
 
 	// handle base file
-	err = g.buildBase(objects, table)
+	err = g.buildBase(table, schema)
 	if err != nil {
-		g.errorChan <- err
-		return
+		return err
+	}
+
+	// handle join table helpers, which write to the table. They import the packages of both linked tables, so
+	// they are left out while either isn't generated
+	joinFile := dir + uppercaseFirst(table) + "_join.go"
+	jt, ok := detectJoinTable(schema.Columns, schema.ForeignKeys)
+	switch {
+	case !ok || g.Features[table].ReadOnly || schema.View:
+		err = g.removeFile(joinFile)
+	case !g.joinLinked(schema):
+		log.Println("Skipping join helpers of "+table+": the packages of", jt.Left.RefTable, "& "+jt.Right.RefTable+" must be generated as well")
+		err = g.removeFile(joinFile)
+	default:
+		err = g.buildJoin(table, jt)
+	}
+	if err != nil {
+		return err
+	}
+
+	// handle typed functions of the annotated .sql files
+	err = g.buildQueries(table, schema)
+	if err != nil {
+		return err
 	}
 
 	// handle extended file
-	g.buildExtended(table)
+	return g.buildExtended(table)
+}
 
-	// handle Test file
-	g.buildTest(table)
+// joinLinked reports whether a table is a join table whose linked tables both have a generated package, which
+// decides whether its join helpers are generated
+func (g Gostruct) joinLinked(schema tableSchema) bool {
+	jt, ok := detectJoinTable(schema.Columns, schema.ForeignKeys)
+	return ok && g.packageGenerated(jt.Left.RefTable) && g.packageGenerated(jt.Right.RefTable)
+}
 
-	g.add <- 1
+// packageGenerated reports whether the package of a table is generated by the current run or a previous one
+func (g Gostruct) packageGenerated(table string) bool {
+	tableNaming := uppercaseFirst(table)
+	return g.selected[table] || exists(g.modelDir+"/"+tableNaming+"/"+tableNaming+"_base.go")
 }
 
 // buildBase builds the {table}_base.go file with main struct and CRUD functionality
-func (g Gostruct) buildBase(objects []tableObj, table string) error {
+func (g Gostruct) buildBase(table string, schema tableSchema) error {
+	objects, indexes := schema.Columns, schema.Indexes
+	features, err := g.tableFeatures(table, schema)
+	if err != nil {
+		return err
+	}
+
 	tableNaming := uppercaseFirst(table)
 	lowerTable := strings.ToLower(table)
 
 	dir := g.modelDir + "/" + tableNaming + "/"
-	importTime, importMysql := false, false
+	imports := map[string]string{
+		g.dbDir:                    "db",
+		"database/sql":             "",
+		"strings":                  "",
+		"golang.org/x/net/context": "",
+	}
 
 	var usedColumns []usedColumn
-	var scanStr, scanStr2, nilExtension, funcName string
+	var modelFields, selectList, fieldMap, funcName, enumTypes, validations string
+	var saveArgs, emptyArgs, omitChecks string
+	var saveColumns, saveUpdates, updates []string
+	var fakeColumns []fakeColumn
 	var primaryKeys, primaryKeyTypes, questionMarks []string
 
 	if g.NameFuncs {
 		funcName = tableNaming
 	}
-	initialString := `// Package ` + tableNaming + ` contains base methods and CRUD functionality to
-// interact with the ` + table + ` table in the ` + g.Database + ` database
-package ` + tableNaming
-	importString := `
+	kind := "table"
+	if schema.View {
+		kind = "view"
+	}
+	initialString := fingerprintPrefix + schema.fingerprint() + " " + schema.fingerprintParts() + `
 
-import (
-	db "` + g.dbDir + `"
-	"database/sql"
-	"reflect"
-	"strings"`
+// Package ` + tableNaming + ` contains base methods and CRUD functionality to
+// interact with the ` + table + ` ` + kind + ` in the ` + g.Database + ` database
+package ` + tableNaming
 
 	nilStruct := `
 	// ` + lowerTable + ` is the nilable structure of the home table
@@ -384,104 +643,71 @@ Loop:
 		usedColumns = append(usedColumns, usedColumn{Name: object.Name})
 		questionMarks = append(questionMarks, "?")
 
-		isBool := true
-		var dataType, nilDataType string
-		switch object.DataType {
-		case "int", "mediumint":
-			isBool = false
-			if object.IsNullable == "YES" {
-				dataType = "*int64"
-				nilDataType = "sql.NullInt64"
-				nilExtension = ".Int64"
-			} else {
-				dataType = "int64"
-				nilDataType = "int64"
-			}
-		case "tinyint", "smallint":
-			con, err := getConnection(g)
-			if err != nil {
-				return err
-			}
-			rows, err := con.Query("SELECT DISTINCT(`" + object.Name + "`) FROM " + g.Database + "." + table)
-			if err != nil {
-				return err
-			}
-			for rows.Next() {
-				var uObj uniqueValues
-				rows.Scan(&uObj.Value)
-				if uObj.Value.String != "0" && uObj.Value.String != "1" && uObj.Value.String != "" {
-					isBool = false
-				}
-			}
-			if isBool {
-				if object.IsNullable == "YES" {
-					dataType = "*bool"
-					nilDataType = "sql.NullBool"
-					nilExtension = ".Bool"
-				} else {
-					dataType = "bool"
-					nilDataType = "bool"
-				}
-			} else {
-				if object.IsNullable == "YES" {
-					dataType = "*int64"
-					nilDataType = "sql.NullInt64"
-					nilExtension = ".Int64"
-				} else {
-					dataType = "int64"
-					nilDataType = "int64"
-				}
-			}
-		case "float", "decimal":
-			isBool = false
-			if object.IsNullable == "YES" {
-				dataType = "*float64"
-				nilDataType = "sql.NullFloat64"
-				nilExtension = ".Float64"
+		if object.DataType == "enum" || object.DataType == "set" {
+			imports["database/sql/driver"], imports["fmt"] = "", ""
+			if object.DataType == "enum" {
+				enumTypes += buildEnumType(table, object)
 			} else {
-				dataType = "float64"
-				nilDataType = "float64"
-			}
-		case "date", "datetime", "timestamp":
-			isBool = false
-			importTime = true
-			if object.IsNullable == "YES" {
-				dataType = "*time.Time"
-				importMysql = true
-				nilDataType = "mysql.NullTime"
-				nilExtension = ".Time"
-			} else {
-				dataType = "time.Time"
-				nilDataType = "time.Time"
-			}
-		default:
-			isBool = false
-			if object.IsNullable == "YES" {
-				dataType = "*string"
-				nilDataType = "sql.NullString"
-				nilExtension = ".String"
-			} else {
-				dataType = "string"
-				nilDataType = "string"
+				enumTypes += buildSetType(table, object)
 			}
 		}
 
-		if object.Key == "PRI" {
-			if isBool {
-				dataType = "int64"
-			}
+		fieldType, overridden := g.columnType(table, object)
+		for path, alias := range fieldType.Imports {
+			imports[path] = alias
+		}
+		validations += buildValidation(table, object, fieldType, overridden, imports)
+		dataType, nilDataType := fieldType.Type, fieldType.NilType
+		fakeColumns = append(fakeColumns, fakeColumn{Object: object, Type: dataType, Imports: fieldType.Imports})
+
+		if features.isKey(object) {
 			primaryKeys = append(primaryKeys, object.Name)
 			primaryKeyTypes = append(primaryKeyTypes, dataType)
 		}
 
 		if i > 0 {
-			if object.IsNullable == "YES" {
-				scanStr2 += ", &obj." + uppercaseFirst(object.Name) + nilExtension
-			} else {
-				scanStr2 += ", obj." + uppercaseFirst(object.Name)
+			modelFields += ", "
+			selectList += ", "
+			saveArgs += ", "
+		}
+		modelFields += fmt.Sprintf(fieldType.Convert, "obj."+uppercaseFirst(object.Name))
+		selectList += "`" + object.Name + "`"
+
+		// the primary key & auto_increment columns are left out of the update list, so a conflict on another unique
+		// key doesn't overwrite the key of the stored record
+		var update string
+		if object.Key != "PRI" && !strings.Contains(object.Extra.String, "auto_increment") {
+			update = "`" + object.Name + "` = VALUES(`" + object.Name + "`)"
+			updates = append(updates, update)
+		}
+		saveColumns = append(saveColumns, "`"+object.Name+"`")
+		saveUpdates = append(saveUpdates, update)
+
+		// values are saved as is, nil pointers are turned into NULL by database/sql. The empty auto_increment
+		// key is saved as NULL, so MySQL assigns the next value
+		saveArgs += "obj." + uppercaseFirst(object.Name)
+		field := "obj." + uppercaseFirst(object.Name)
+		if strings.Contains(object.Extra.String, "auto_increment") {
+			if empty := zeroCheck(table, object, dataType, field); empty != "" {
+				emptyArgs += `
+	if ` + empty + ` {
+		args[` + strconv.Itoa(len(usedColumns)-1) + `] = nil
+	}`
+			}
+		}
+
+		// empty dates & enums can't be stored, so they are left out of the save to take the default of their
+		// column, or keep the stored value
+		if object.IsNullable == "NO" && object.Default.Valid && strings.ToLower(object.Default.String) != "null" &&
+			!features.isKey(object) && object.Name != features.Version && (dataType == "time.Time" || object.DataType == "enum") {
+			if empty := zeroCheck(table, object, dataType, field); empty != "" {
+				omitChecks += `
+	if ` + empty + ` {
+		omit = append(omit, ` + strconv.Itoa(len(usedColumns)-1) + `)
+	}`
 			}
-			scanStr += ", &obj." + uppercaseFirst(object.Name)
 		}
+		fieldMap += "\n\t\"" + strings.ToLower(object.Name) + "\": func(obj *" + lowerTable + ") interface{} { return &obj." + uppercaseFirst(object.Name) + " },"
 
 		var defaultVal string
 		if strings.ToLower(object.Default.String) != "null" {
@@ -490,302 +716,528 @@ Loop:
 		string1 += "\n\t" + uppercaseFirst(object.Name) + "\t\t" + dataType + "\t\t`column:\"" + object.Name + "\" default:\"" + defaultVal + "\" type:\"" + object.ColumnType + "\" key:\"" + object.Key + "\" null:\"" + object.IsNullable + "\" extra:\"" + object.Extra.String + "\"`"
 		nilStruct += "\n\t" + uppercaseFirst(object.Name) + "\t\t" + nilDataType
 	}
-	string1 += "\n}" + nilStruct + "\n}\n"
+	whereStrQuery, whereStrQueryValues := "", ""
+	for k := range primaryKeys {
+		if k > 0 {
+			whereStrQuery += " AND"
+			whereStrQueryValues += ","
+		}
+		whereStrQuery += " `" + primaryKeys[k] + "` = ?"
+		whereStrQueryValues += ` obj.` + uppercaseFirst(primaryKeys[k])
+	}
+
+	// the columns of a versioned record are only updated when its version still matches the stored one. The
+	// version is assigned last, since MySQL assigns the columns from left to right
+	saveComment := "inserts a record or updates every column but the key when the key already exists"
+	if features.Version != "" {
+		version := "`" + features.Version + "`"
+		updates = nil
+		for i, c := range usedColumns {
+			if saveUpdates[i] == "" {
+				continue
+			}
+			saveUpdates[i] = ""
+			if c.Name != features.Version {
+				column := "`" + c.Name + "`"
+				saveUpdates[i] = column + " = IF(" + version + " = VALUES(" + version + "), VALUES(" + column + "), " + column + ")"
+				updates = append(updates, saveUpdates[i])
+			}
+		}
+		versionUpdate := version + " = IF(" + version + " = VALUES(" + version + "), " + version + " + 1, " + version + ")"
+		saveUpdates = append(saveUpdates, versionUpdate)
+		updates = append(updates, versionUpdate)
+		saveComment = "inserts a record, or updates every column but the key & increments the version when the key\n\t// already exists and the version still matches"
+	}
 
-	if importTime {
-		importString += `
-		"time"`
+	// a table of key columns only has nothing to update, so its key is assigned to itself
+	if len(updates) == 0 && len(primaryKeys) > 0 {
+		updates = []string{"`" + primaryKeys[0] + "` = `" + primaryKeys[0] + "`"}
 	}
-	importString += `
-	`
-	if importMysql {
-		importString += `
-		"github.com/go-sql-driver/mysql"`
+	updateList := strings.Join(updates, ", ")
+
+	queries := `
+	// selectQuery reads every column of the ` + table + ` table
+	selectQuery = "SELECT ` + selectList + ` FROM ` + "`" + table + "`" + `"`
+
+	// soft deleted records are left out of ReadByKey & ReadAll
+	readAllQuery, keyFilter := "selectQuery", ""
+	if features.SoftDelete != "" {
+		readAllQuery, keyFilter = "liveQuery", " AND `"+features.SoftDelete+"` IS NULL"
+		queries += `
+	// liveQuery reads the records of the ` + table + ` table that aren't soft deleted
+	liveQuery = selectQuery + " WHERE ` + strings.TrimPrefix(keyFilter, " AND ") + `"`
+	}
+	queries += `
+	// SelectQuery reads the records of the ` + table + ` table that ReadAll returns, for the queries of other packages
+	SelectQuery = ` + readAllQuery
+
+	if len(primaryKeys) > 0 && !features.ReadOnly {
+		queries += `
+	// saveQuery ` + saveComment + `
+	saveQuery = "INSERT INTO ` + "`" + table + "`" + ` (` + selectList + `) VALUES (` + strings.Join(questionMarks, ", ") + `) ON DUPLICATE KEY UPDATE ` + updateList + `"`
+		if features.SoftDelete != "" {
+			queries += `
+	// deleteQuery marks a record as deleted by its primary key
+	deleteQuery = "UPDATE ` + "`" + table + "`" + ` SET ` + "`" + features.SoftDelete + "`" + ` = NOW() WHERE` + whereStrQuery + keyFilter + `"`
+		} else {
+			queries += `
+	// deleteQuery removes a record by its primary key
+	deleteQuery = "DELETE FROM ` + "`" + table + "`" + ` WHERE` + whereStrQuery + `"`
+		}
 	}
 
-	importString += `
-		"github.com/pkg/errors"
-		"golang.org/x/net/context"`
+	// the columns & update list of saveQuery, for the saves that leave out empty columns
+	var saveVars string
+	if omitChecks != "" && len(primaryKeys) > 0 && !features.ReadOnly {
+		quote := func(values []string) string {
+			quoted := make([]string, len(values))
+			for i, value := range values {
+				quoted[i] = strconv.Quote(value)
+			}
+			return strings.Join(quoted, ", ")
+		}
+		saveVars = `
 
-	if len(primaryKeys) == 1 {
-		string1 += `
+// saveColumns & saveUpdates are the columns & the update list of saveQuery, for the saves that leave out empty
+// columns
+var (
+	saveColumns = []string{` + quote(saveColumns) + `}
+	saveUpdates = []string{` + quote(saveUpdates) + `}
+)`
+	}
+
+	string1 += "\n}" + nilStruct + "\n}\n" + enumTypes + `
+
+const (` + queries + `
+)` + saveVars + `
+
+// columnFields maps every column of the ` + table + ` table to its field in the nilable structure
+var columnFields = map[string]func(*` + lowerTable + `) interface{}{` + fieldMap + `
+}
+
+// scanDest returns the scan destinations for the columns of a result set, matched by name. Columns that are
+// not part of the ` + table + ` table are discarded
+func (obj *` + lowerTable + `) scanDest(columns []string) []interface{} {
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		if field, ok := columnFields[strings.ToLower(column)]; ok {
+			dest[i] = field(obj)
+		} else {
+			dest[i] = new(interface{})
+		}
+	}
+	return dest
+}
+
+// toModel converts the nilable structure into a(n) ` + tableNaming + `, leaving NULL columns nil
+func (obj *` + lowerTable + `) toModel() *` + tableNaming + ` {
+	return &` + tableNaming + `{` + modelFields + `}
+}
+
+// scan returns the scan destinations for the columns of a result set and a function that converts the
+// scanned values into a(n) ` + tableNaming + `
+func scan(columns []string) ([]interface{}, func() *` + tableNaming + `) {
+	var obj ` + lowerTable + `
+	return obj.scanDest(columns), obj.toModel
+}
 
 // TableName returns the name of the mysql table
 func (obj *` + tableNaming + `) TableName() string {
 	return "` + table + `"
 }
 
+// Validate checks every value against the definition of its column in the ` + table + ` table. All
+// violations are returned together in a *db.ValidationError
+func (obj *` + tableNaming + `) Validate() error {
+	var fields []db.FieldError` + validations + `
+
+	if len(fields) > 0 {
+		return &db.ValidationError{Fields: fields}
+	}
+	return nil
+}`
+
+	if len(primaryKeys) == 1 {
+		string1 += `
+
 // PrimaryKeyInfo returns the string value of the primary key column and the corresponding value for the receiver
 func (obj *` + tableNaming + `) PrimaryKeyInfo() (string, interface{}) {
-	val := reflect.ValueOf(obj).Elem()
-	var objTypeId interface{}
-	for i := 0; i < val.NumField(); i++ {
-		valueField := val.Field(i)
-		column := val.Type().Field(i).Tag.Get("column")
-		if "` + primaryKeys[0] + `" == column {
-			switch valueField.Kind() {
-			case reflect.Int:
-				objTypeId = valueField.Interface().(int)
-			case reflect.Int64:
-				objTypeId = valueField.Interface().(int64)
-			case reflect.String:
-				objTypeId = valueField.Interface().(string)
+	return "` + primaryKeys[0] + `", obj.` + uppercaseFirst(primaryKeys[0]) + `
+}
+
+// TypeInfo implements mysql.Info interface to allow for retrieving type/typeId for any db model
+func (obj *` + tableNaming + `) TypeInfo() (string, interface{}) {
+	_, pkVal := obj.PrimaryKeyInfo()
+	return "` + table + `", pkVal
+}`
+	} else if len(primaryKeys) > 1 {
+		keyFields, keyValues := "", ""
+		for k := range primaryKeys {
+			keyFields += "\n\t" + uppercaseFirst(primaryKeys[k]) + "\t" + primaryKeyTypes[k]
+			if k > 0 {
+				keyValues += ", "
 			}
+			keyValues += "obj." + uppercaseFirst(primaryKeys[k])
 		}
-	}
 
-	return "` + primaryKeys[0] + `", objTypeId
+		string1 += `
+
+// ` + tableNaming + `Key is the composite primary key of the ` + table + ` table
+type ` + tableNaming + `Key struct {` + keyFields + `
+}
+
+// PrimaryKey returns the composite primary key of the receiver
+func (obj *` + tableNaming + `) PrimaryKey() ` + tableNaming + `Key {
+	return ` + tableNaming + `Key{` + keyValues + `}
+}
+
+// PrimaryKeyInfo returns the comma-separated primary key columns and the composite key of the receiver
+func (obj *` + tableNaming + `) PrimaryKeyInfo() (string, interface{}) {
+	return "` + strings.Join(primaryKeys, ",") + `", obj.PrimaryKey()
 }
 
 // TypeInfo implements mysql.Info interface to allow for retrieving type/typeId for any db model
 func (obj *` + tableNaming + `) TypeInfo() (string, interface{}) {
-	_, pkVal := obj.PrimaryKeyInfo()
-	return "` + table + `", pkVal
+	return "` + table + `", obj.PrimaryKey()
 }`
 	}
 
 	if len(primaryKeys) > 0 {
 		string1 += `
 
-// Save runs an INSERT..UPDATE ON DUPLICATE KEY and validates each value being saved
-func (obj *` + tableNaming + `) ` + funcName + `Save(ctx context.Context) (sql.Result, error) {
-	v := reflect.ValueOf(obj).Elem()
-	args, columns, q, updateStr, err := db.BuildQuery(v, v.Type())
-	if err != nil {
-		return nil, errors.Wrap(err, "field validation error")
+var _ db.Info = (*` + tableNaming + `)(nil)`
 	}
-	query := "INSERT INTO ` + table + ` (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(q, ", ") + ") ON DUPLICATE KEY UPDATE " + updateStr
-	newArgs := append(args, args...)`
 
-		if len(primaryKeys) > 1 {
-			string1 += `
-
-	return ` + funcName + `Exec(ctx, query, newArgs...)`
-		} else {
-			var insertIdStr string
+	var keyMethods, keyFuncs, keyRepoMethods, keyParam, keyArgs, insertIdStr string
+	if len(primaryKeys) > 0 {
+		if len(primaryKeys) == 1 {
 			switch primaryKeyTypes[0] {
 			case "string":
 				insertIdStr = "strconv.FormatInt(id, 10)"
-				importString += `
-				"strconv"`
-			default:
+				imports["strconv"] = ""
+			case "int64":
 				insertIdStr = `id`
+			case "int16", "uint8", "uint16", "uint32", "uint64":
+				insertIdStr = primaryKeyTypes[0] + `(id)`
 			}
+		}
 
-			string1 += `
-			newRecord := false
-			if obj.` + uppercaseFirst(primaryKeys[0]) + ` == 0 {
-				newRecord = true
-			}
-
-			res, err := ` + funcName + `Exec(ctx, query, newArgs...)
-			if err == nil && newRecord {
-				id, _ := res.LastInsertId()
-				obj.` + uppercaseFirst(primaryKeys[0]) + ` = ` + insertIdStr + `
+		paramStr, paramName, whereStrValues := "", "key", ""
+		if len(primaryKeys) > 1 {
+			paramStr = "key " + tableNaming + "Key"
+			for k := range primaryKeys {
+				if k > 0 {
+					whereStrValues += ","
+				}
+				whereStrValues += " key." + uppercaseFirst(primaryKeys[k])
 			}
-			if err != nil {
-				err = errors.Wrap(err, "save failed for ` + table + `")
+		} else {
+			var param string
+			if primaryKeys[0] == "type" {
+				param = "objType"
+			} else if primaryKeys[0] == "typeId" {
+				param = "objTypeId"
+			} else {
+				param = primaryKeys[0]
 			}
 
-			return res, err`
+			paramStr = param + " " + primaryKeyTypes[0]
+			paramName = param
+			whereStrValues = " " + param
 		}
 
-		whereStrQuery, whereStrQueryValues := "", ""
-		for k := range primaryKeys {
-			if k > 0 {
-				whereStrQuery += " AND"
-				whereStrQueryValues += ","
-			}
-			whereStrQuery += ` ` + primaryKeys[k] + ` = ?`
-			whereStrQueryValues += ` obj.` + uppercaseFirst(primaryKeys[k])
+		keyParam, keyArgs = paramStr, strings.TrimSpace(whereStrValues)
+
+		keyMethods = `
+	ReadByKey(ctx context.Context, ` + paramStr + `) (*` + tableNaming + `, error)`
+
+		keyFuncs = `
+
+// ReadByKey returns a single pointer to a(n) ` + tableNaming + `
+func Read` + funcName + `ByKey(ctx context.Context, ` + paramStr + `) (*` + tableNaming + `, error) {
+	return defaultRepository.ReadByKey(ctx, ` + paramName + `)
+}`
+
+		keyRepoMethods = `
+
+// ReadByKey returns a single pointer to a(n) ` + tableNaming + `
+func (r *repository) ReadByKey(ctx context.Context, ` + paramStr + `) (*` + tableNaming + `, error) {
+	return db.FindOne(ctx, r.repo, selectQuery+" WHERE` + whereStrQuery + keyFilter + `", ` + whereStrValues + `)
+}`
+	}
+
+	// read-only tables have no Save & Delete
+	if len(primaryKeys) > 0 && !features.ReadOnly {
+		saveDoc := "// Save runs an INSERT..UPDATE ON DUPLICATE KEY and validates each value being saved"
+		if features.Version != "" {
+			saveDoc += ". The record is only\n// updated when its version still matches the stored one, db.ErrStale is returned otherwise"
+		}
+		deleteDoc := "// Delete removes a record from the database according to the primary key"
+		if features.SoftDelete != "" {
+			deleteDoc = "// Delete marks a record as deleted according to the primary key"
 		}
 
-		string1 += `
+		keyMethods += `
+	Save(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error)
+	Delete(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error)`
+
+		saveStatement := `// saveStatement returns saveQuery & the value of every column in its order
+func (obj *` + tableNaming + `) saveStatement() (string, []interface{}) {
+	args := []interface{}{` + saveArgs + `}` + emptyArgs + `
+
+	return saveQuery, args
+}`
+		if saveVars != "" {
+			saveStatement = `// saveStatement returns saveQuery & the value of every column in its order. Empty columns that can't be
+// stored are left out of the query, so a new record takes their defaults & an existing one keeps its values
+func (obj *` + tableNaming + `) saveStatement() (string, []interface{}) {
+	args := []interface{}{` + saveArgs + `}` + emptyArgs + `
+
+	var omit []int` + omitChecks + `
+	if len(omit) > 0 {
+		return db.OmitColumns("` + table + `", saveColumns, saveUpdates, args, omit)
+	}
+
+	return saveQuery, args
+}`
+		}
+
+		keyFuncs = `
+
+` + saveDoc + `
+func (obj *` + tableNaming + `) ` + funcName + `Save(ctx context.Context) (sql.Result, error) {
+	return defaultRepository.Save(ctx, obj)
 }
 
-// Delete removes a record from the database according to the primary key
+` + saveStatement + `
+
+` + deleteDoc + `
 func (obj *` + tableNaming + `) ` + funcName + `Delete(ctx context.Context) (sql.Result, error) {
-	return ` + funcName + `Exec(ctx, "DELETE FROM ` + table + ` WHERE` + whereStrQuery + `", ` + whereStrQueryValues + `)
-}
-`
-		paramStr, whereStrValues := "", ""
-		for k := range primaryKeys {
-			var param string
-			if primaryKeys[k] == "type" {
-				param = "objType"
-			} else if primaryKeys[k] == "typeId" {
-				param = "objTypeId"
-			} else {
-				param = primaryKeys[k]
-			}
+	return defaultRepository.Delete(ctx, obj)
+}` + keyFuncs
 
-			var dataType string
-			switch primaryKeyTypes[k] {
-			case "int64":
-				dataType = "int64"
-			case "float64":
-				dataType = "float64"
-			default:
-				dataType = "string"
-			}
+		keyRepoMethods += `
 
-			paramStr += param + " " + dataType
-			if k > 0 {
-				whereStrValues += ","
+` + saveDoc + `
+func (r *repository) Save(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error) {`
+
+		var newRecord, setID string
+		if insertIdStr != "" {
+			zero := "0"
+			if primaryKeyTypes[0] == "string" {
+				zero = `""`
 			}
-			if k != len(primaryKeys)-1 {
-				paramStr += ", "
+
+			newRecord = `
+	newRecord := obj.` + uppercaseFirst(primaryKeys[0]) + ` == ` + zero + `
+`
+			setID = `
+		id, _ := res.LastInsertId()
+		obj.` + uppercaseFirst(primaryKeys[0]) + ` = ` + insertIdStr
+		}
+
+		switch {
+		case features.Version != "":
+			keyRepoMethods += newRecord + `
+	query, args := obj.saveStatement()
+	res, err := r.repo.SaveVersioned(ctx, obj, query, args)
+	if err != nil {
+		return res, err
+	}`
+			if setID != "" {
+				keyRepoMethods += `
+	if newRecord {` + setID + `
+	}`
 			}
-			whereStrValues += " " + param
+			keyRepoMethods += `
+
+	// the stored version was incremented when the record was updated
+	if rows, _ := res.RowsAffected(); rows == 2 {
+		obj.` + uppercaseFirst(features.Version) + `++
+	}
+
+	return res, nil`
+		case insertIdStr == "":
+			keyRepoMethods += `
+	query, args := obj.saveStatement()
+	return r.repo.Save(ctx, obj, query, args)`
+		default:
+			keyRepoMethods += newRecord + `
+	query, args := obj.saveStatement()
+	res, err := r.repo.Save(ctx, obj, query, args)
+	if err == nil && newRecord {` + setID + `
+	}
+
+	return res, err`
 		}
 
-		// create ReadByKey method
-		string1 += `
-// ReadByKey returns a single pointer to a(n) ` + tableNaming + `
-func Read` + funcName + `ByKey(ctx context.Context, ` + paramStr + `) (*` + tableNaming + `, error) {
-	return ReadOne` + funcName + `ByQuery(ctx, "SELECT * FROM ` + table + ` WHERE` + whereStrQuery + `", ` + whereStrValues + `)
+		keyRepoMethods += `
+}
+
+` + deleteDoc + `
+func (r *repository) Delete(ctx context.Context, obj *` + tableNaming + `) (sql.Result, error) {
+	return r.repo.Exec(ctx, deleteQuery, ` + whereStrQueryValues + `)
 }`
 	}
 
-	string1 += `
+	string1 += keyFuncs + `
 
 // ReadAll returns all records in the table
 func ReadAll` + funcName + `(ctx context.Context, options ...db.QueryOptions) ([]*` + tableNaming + `, error) {
-	return Read` + funcName + `ByQuery(ctx, "SELECT * FROM ` + table + `", options)
+	return defaultRepository.ReadAll(ctx, options...)
 }
 
 // ReadByQuery returns an array of ` + tableNaming + ` pointers
 func Read` + funcName + `ByQuery(ctx context.Context, query string, args ...interface{}) ([]*` + tableNaming + `, error) {
-	var objects []*` + tableNaming + `
+	return defaultRepository.ReadByQuery(ctx, query, args...)
+}
 
-	con := db.GetConnection()
+// ReadOneByQuery returns a single pointer to a(n) ` + tableNaming + `
+func ReadOne` + funcName + `ByQuery(ctx context.Context, query string, args ...interface{}) (*` + tableNaming + `, error) {
+	return defaultRepository.ReadOneByQuery(ctx, query, args...)
+}
 
-	newArgs := db.ApplyQueryOptions(&query, args)
-	query = strings.Replace(query, "'", "\"", -1)
-	rows, err := con.QueryContext(ctx, query, newArgs...)
-	if err != nil {
-		return objects, errors.Wrap(err, "query error")
-	}
+// StreamByQuery calls fn for every ` + tableNaming + ` returned by the query as it is read
+func Stream` + funcName + `ByQuery(ctx context.Context, query string, fn func(*` + tableNaming + `) error, args ...interface{}) error {
+	return defaultRepository.StreamByQuery(ctx, query, fn, args...)
+}
 
-	rowsErr := rows.Err()
-	if rowsErr != nil {
-		return objects, errors.Wrap(err, "rows error")
-	}
+// Exec allows for update queries. MySQL errors are matched to the sentinel errors of the connection package
+func ` + funcName + `Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return defaultRepository.Exec(ctx, query, args...)
+}
 
-	defer rows.Close()
-	for rows.Next() {
-		var obj ` + lowerTable + `
-		err = rows.Scan(&obj.` + uppercaseFirst(objects[0].Name) + scanStr + `)
-		if err != nil {
-			return objects, errors.Wrap(err, "scan error")
-		}
-		objects = append(objects, &` + tableNaming + `{obj.` + uppercaseFirst(objects[0].Name) + scanStr2 + `})
-	}
+// ` + tableNaming + `Repository reads & writes the records of the ` + table + ` table. Service code can depend on it
+// instead of the package functions, so tests can substitute another implementation
+type ` + tableNaming + `Repository interface {` + keyMethods + `
+	ReadAll(ctx context.Context, options ...db.QueryOptions) ([]*` + tableNaming + `, error)
+	ReadByQuery(ctx context.Context, query string, args ...interface{}) ([]*` + tableNaming + `, error)
+	ReadOneByQuery(ctx context.Context, query string, args ...interface{}) (*` + tableNaming + `, error)
+	StreamByQuery(ctx context.Context, query string, fn func(*` + tableNaming + `) error, args ...interface{}) error
+	Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
 
-	if len(objects) == 0 {
-		err = errors.Wrap(sql.ErrNoRows, "no records found")
-	}
+// repository implements ` + tableNaming + `Repository through the shared helpers of the connection package
+type repository struct {
+	repo db.Repo[*` + tableNaming + `]
+}
+
+var _ ` + tableNaming + `Repository = (*repository)(nil)
+
+// defaultRepository runs every query of the package functions through the shared connection to the ` + g.Database + ` database
+var defaultRepository = &repository{repo: db.Repo[*` + tableNaming + `]{Database: "` + g.Database + `", Table: "` + table + `", Scan: scan}}
+
+// NewRepository returns a(n) ` + tableNaming + `Repository that runs every query through ex, such as a *sql.DB or a *sql.Tx
+func NewRepository(ex db.Executor) ` + tableNaming + `Repository {
+	r := defaultRepository.repo
+	r.Executor = ex
+	return &repository{repo: r}
+}` + keyRepoMethods + `
 
-	return objects, err
+// ReadAll returns all records in the table
+func (r *repository) ReadAll(ctx context.Context, options ...db.QueryOptions) ([]*` + tableNaming + `, error) {
+	return db.Find(ctx, r.repo, ` + readAllQuery + `, options)
 }
 
-// ReadOneByQuery returns a single pointer to a(n) ` + tableNaming + `
-func ReadOne` + funcName + `ByQuery(ctx context.Context, query string, args ...interface{}) (*` + tableNaming + `, error) {
-	var obj ` + lowerTable + `
+// ReadByQuery returns an array of ` + tableNaming + ` pointers
+func (r *repository) ReadByQuery(ctx context.Context, query string, args ...interface{}) ([]*` + tableNaming + `, error) {
+	return db.Find(ctx, r.repo, query, args...)
+}
 
-	con := db.GetConnection()
-	query = strings.Replace(query, "'", "\"", -1)
-	err := con.QueryRowContext(ctx, query, args...).Scan(&obj.` + uppercaseFirst(objects[0].Name) + scanStr + `)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, errors.Wrap(err, "query/scan error")
-	}
+// ReadOneByQuery returns a single pointer to a(n) ` + tableNaming + `
+func (r *repository) ReadOneByQuery(ctx context.Context, query string, args ...interface{}) (*` + tableNaming + `, error) {
+	return db.FindOne(ctx, r.repo, query, args...)
+}
 
-	return &` + tableNaming + `{obj.` + uppercaseFirst(objects[0].Name) + scanStr2 + `}, err
+// StreamByQuery calls fn for every ` + tableNaming + ` returned by the query as it is read
+func (r *repository) StreamByQuery(ctx context.Context, query string, fn func(*` + tableNaming + `) error, args ...interface{}) error {
+	return db.Stream(ctx, r.repo, query, fn, args...)
 }
 
-// Exec allows for update queries
-func ` + funcName + `Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	con := db.GetConnection()
-	return con.ExecContext(ctx, query, args...)
+// Exec allows for update queries. MySQL errors are matched to the sentinel errors of the connection package
+func (r *repository) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.repo.Exec(ctx, query, args...)
 }`
 
-	importString += "\n)"
-
 	autoGenFile := dir + tableNaming + "_base.go"
-	err := writeFile(autoGenFile, initialString+importString+string1, true)
+	err = g.writeGoFile(autoGenFile, initialString+importBlock(imports)+string1, true)
 	if err != nil {
-		g.errorChan <- err
+		return err
 	}
 
-	_, err = runCommand("go fmt " + autoGenFile)
+	err = g.buildFake(table, features, fakeColumns, primaryKeys, keyParam, keyArgs, indexes)
 	if err != nil {
-		g.errorChan <- err
+		return err
 	}
 
-	return nil
+	return g.buildTest(table, features, fakeColumns, primaryKeys, funcName)
 }
 
 // buildExtended builds the {table}_extends.go file for custom functions & methods
-func (g Gostruct) buildExtended(table string) {
+func (g Gostruct) buildExtended(table string) error {
 	tableNaming := uppercaseFirst(table)
 	dir := g.modelDir + "/" + tableNaming + "/"
 	extendedFilePath := dir + tableNaming + "_extended.go"
 
-	if !exists(extendedFilePath) {
-		contents := "package " + tableNaming + "\n\n// Methods Here"
-		err := writeFile(extendedFilePath, contents, false)
-		if err != nil {
-			g.errorChan <- err
-		}
-		_, err = runCommand("go fmt " + extendedFilePath)
+	contents := "package " + tableNaming + "\n\n// Methods Here"
+	return g.writeGoFile(extendedFilePath, contents, false)
+}
+
+// buildConnectionPkg builds the main connection package for serving up all database connections
+// with a shared connection pool
+func (g Gostruct) buildConnectionPkg() error {
+	dir := GOPATH + "/src/" + g.dbDir
+	if !exists(dir) && !g.preview() {
+		err := createDirectory(dir)
 		if err != nil {
-			g.errorChan <- err
+			return err
 		}
 	}
-}
 
-// buildTest builds the skeleton {table}_test.go file to hold all unit tests
-func (g Gostruct) buildTest(table string) {
-	tableNaming := uppercaseFirst(table)
-	dir := g.modelDir + "/" + tableNaming + "/"
-	testFilePath := dir + tableNaming + "_test.go"
-
-	if !exists(testFilePath) {
-		contents := `package ` + tableNaming + `_test
-
-		import (
-			"testing"
-		)
-
-		func TestSomething(t *testing.T) {
-			// test stuff here..
-		}`
-		// g.write <- filewrite{testFilePath, contents, false}
-		err := writeFile(testFilePath, contents, false)
+	// connection.go is only created once, so a connection.go of a gostruct version before the shared runtime
+	// & the configuration file lacks Get's lookup of dataSources, and may declare BuildQuery, getValue &
+	// GetConnection, which clash with the rewritten files or are gone. It has to be regenerated
+	conFilePath := dir + "/connection.go"
+	if exists(conFilePath) {
+		data, err := os.ReadFile(conFilePath)
 		if err != nil {
-			g.errorChan <- err
+			return err
 		}
-		_, err = runCommand("go fmt " + testFilePath)
-		if err != nil {
-			g.errorChan <- err
+		if !strings.Contains(string(data), "dataSources[") {
+			return errors.New(conFilePath + " was generated by an older version of gostruct, remove it to regenerate it and move any custom changes to another file of the package")
 		}
 	}
-}
 
-// buildConnectionPkg builds the main connection package for serving up all database connections
-// with a shared connection pool
-func (g Gostruct) buildConnectionPkg() error {
-	if !exists(GOPATH + "/src/connection") {
-		err := createDirectory(GOPATH + "/src/connection")
+	for name, contents := range connectionFiles {
+		err := g.writeGoFile(dir+"/"+name, contents, true)
 		if err != nil {
 			return err
 		}
-	} else if exists(GOPATH + "/src/connection/connection.go") {
-		return nil
 	}
 
-	bs := "`"
-	conFilePath := GOPATH + "/src/connection/connection.go"
+	// the data sources of the configured databases are rewritten on every run, unlike connection.go
+	var databases []string
+	for database := range g.Connections {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+
+	var dataSources string
+	for _, database := range databases {
+		dataSources += "\n\t" + strconv.Quote(database) + ": " + strconv.Quote(g.dataSourceName(database)) + ","
+	}
+
+	err := g.writeGoFile(dir+"/datasources.go", `package connection
+
+// dataSources holds the data source name of every database with connection settings in the configuration
+// file. Get connects to other databases with the settings the package was generated with
+var dataSources = map[string]string{`+dataSources+`
+}
+`, true)
+	if err != nil {
+		return err
+	}
+
 	contents := `// Package connection handles all connections to the MySQL database(s)
 package connection
 
@@ -793,10 +1245,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"reflect"
-	"strings"
 	"sync"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -840,7 +1289,12 @@ func Get(db string) (*sql.DB, error) {
 		}
 	}
 
-	con, err := sql.Open("mysql", fmt.Sprintf("` + g.Username + `:` + g.Password + `@tcp(` + g.Host + `:3306)/%s?parseTime=true", db))
+	dsn, ok := dataSources[db]
+	if !ok {
+		dsn = fmt.Sprintf("` + g.Username + `:` + g.Password + `@tcp(` + g.Host + `:` + g.Port + `)/%s?parseTime=true", db)
+	}
+
+	con, err := sql.Open("mysql", dsn)
 	if err != nil {
 		// do whatever tickles your fancy here
 		log.Fatalln("Connection Error to DB [", db, "]", err.Error())
@@ -886,144 +1340,8 @@ func ApplyQueryOptions(query *string, args []interface{}) []interface{} {
 
 	return newArgs
 }
-
-// BuildQuery returns all necessary arguments for the Save method of a type
-func BuildQuery(v reflect.Value, valType reflect.Type) ([]interface{}, []string, []string, string, error) {
-	var columns []string
-	var q []string
-	var updateStr string
-	var args []interface{}
-
-	for i := 0; i < v.NumField(); i++ {
-		val, err := getValue(v.Field(i), valType.Field(i))
-		if err != nil {
-			return nil, columns, q, "", err
-		}
-		args = append(args, val)
-		column := string(valType.Field(i).Tag.Get("column"))
-		columns = append(columns, "` + bs + `"+column+"` + bs + `")
-		q = append(q, "?")
-		if i > 0 && updateStr != "" {
-			updateStr += ", "
-		}
-		updateStr += "` + bs + `" + column + "` + bs + ` = ?"
-	}
-
-	return args, columns, q, updateStr, nil
-}
-
-func isEmpty(val interface{}) bool {
-	empty := false
-	switch v := val.(type) {
-	case string:
-		if v == "" {
-			empty = true
-		}
-	case int:
-		if v == 0 {
-			empty = true
-		}
-	case int64:
-		if v == int64(0) {
-			empty = true
-		}
-	case float64:
-		if v == float64(0) {
-			empty = true
-		}
-	case bool:
-		if v == false {
-			empty = true
-		}
-	case time.Time:
-		if v.IsZero() {
-			empty = true
-		}
-	case *string, *int, *int64, *float64, *bool, *time.Time:
-		if v == nil {
-			empty = true
-		}
-	}
-	return empty
-}
-
-func getValue(val reflect.Value, field reflect.StructField) (interface{}, error) {
-	var value interface{}
-
-	column := field.Tag.Get("column")
-	fieldType := string(field.Tag.Get("type"))
-	if strings.Contains(fieldType, "enum") {
-		var s string
-		switch t := val.Interface().(type) {
-		case string:
-			s = t
-		case *string:
-			if t == nil {
-				s = "NULL"
-			} else {
-				s = *t
-			}
-		}
-		s2 := strings.Replace(fieldType, "enum('", "", 1)
-		s2 = strings.Replace(s2, "')", "", 1)
-		arr := strings.Split(s2, "','")
-		if !inArray(s, arr) {
-			return nil, fmt.Errorf("Invalid value: %s for column: %s. Possible values are: %s", s, column, strings.Join(arr, ", "))
-		}
-	}
-
-	if val.Kind() == reflect.Interface && !val.IsNil() {
-		elm := val.Elem()
-		if elm.Kind() == reflect.Ptr && !elm.IsNil() && elm.Elem().Kind() == reflect.Ptr {
-			val = elm
-		}
-	}
-
-	if val.Kind() == reflect.Ptr {
-		if val.IsNil() {
-			value = nil
-		} else {
-			value = val.Elem().Interface()
-		}
-	} else {
-		value = val.Interface()
-	}
-
-	if IsEmpty(value) {
-		if field.Tag.Get("key") != "PRI" {
-			value = field.Tag.Get("default")
-			if value == "" && field.Tag.Get("null") == "NO" {
-				return nil, fmt.Errorf("you must provide a value for column: %s", column)
-			}
-		}
-		value = nil
-	}
-
-	return value, nil
-}
-
-// inArray determines whether or not a string is in a string array
-func inArray(char string, strings []string) bool {
-	for _, a := range strings {
-		if a == char {
-			return true
-		}
-	}
-	return false
-}
-
 `
-	err := writeFile(conFilePath, contents, false)
-	if err != nil {
-		return err
-	}
-
-	_, err = runCommand("go fmt " + conFilePath)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return g.writeGoFile(conFilePath, contents, false)
 }
 
 // startTimer keeps a timer of the duration of the process
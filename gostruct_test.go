@@ -0,0 +1,978 @@
+package gostruct
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// column returns the metadata of a column. The length, precision & scale are parsed from the column type
+func column(name, nullable, key, dataType, columnType, def, extra string) tableObj {
+	object := tableObj{
+		Name:       name,
+		IsNullable: nullable,
+		Key:        key,
+		DataType:   dataType,
+		ColumnType: columnType,
+		Default:    sql.NullString{String: def, Valid: def != ""},
+		Extra:      sql.NullString{String: extra, Valid: true},
+	}
+
+	var length, scale int64
+	switch dataType {
+	case "char", "varchar", "binary", "varbinary":
+		fmt.Sscanf(columnType[strings.Index(columnType, "("):], "(%d)", &length)
+		object.CharMaxLength = sql.NullInt64{Int64: length, Valid: true}
+	case "decimal":
+		fmt.Sscanf(columnType[strings.Index(columnType, "("):], "(%d,%d)", &length, &scale)
+		object.NumericPrecision = sql.NullInt64{Int64: length, Valid: true}
+		object.NumericScale = sql.NullInt64{Int64: scale, Valid: true}
+	}
+
+	return object
+}
+
+// boolColumn returns the metadata of a tinyint column that only holds 0, 1 or NULL
+func boolColumn(name, nullable, def string) tableObj {
+	object := column(name, nullable, "", "tinyint", "tinyint(1)", def, "")
+	object.IsBool = true
+	return object
+}
+
+// fixtures covers every column type the generator maps, tables with a single, a composite & no primary key,
+// unique indexes, a join table, a view and the table features
+var fixtures = map[string]tableSchema{
+	"user": {
+		Columns: []tableObj{
+			column("id", "NO", "PRI", "int", "int(11)", "", "auto_increment"),
+			column("name", "NO", "", "varchar", "varchar(45)", "", ""),
+			column("email", "NO", "UNI", "varchar", "varchar(200)", "", ""),
+			column("age", "YES", "", "int", "int(11)", "", ""),
+			boolColumn("active", "NO", "1"),
+			boolColumn("verified", "YES", ""),
+			column("level", "NO", "", "tinyint", "tinyint(3) unsigned", "0", ""),
+			column("rank", "YES", "", "smallint", "smallint(6)", "", ""),
+			column("status", "NO", "", "enum", "enum('active','inactive')", "active", ""),
+			column("kind", "YES", "", "enum", "enum('a-b','','9lives')", "", ""),
+			column("perms", "NO", "", "set", "set('read','write','in progress','it''s')", "", ""),
+			column("big", "NO", "", "bigint", "bigint(20)", "", ""),
+			column("ubig", "YES", "", "bigint", "bigint(20) unsigned", "", ""),
+			column("umed", "NO", "", "mediumint", "mediumint(8) unsigned", "", ""),
+			column("score", "YES", "", "double", "double", "", ""),
+			column("ratio", "NO", "", "float", "float", "0", ""),
+			column("price", "YES", "", "decimal", "decimal(10,2)", "", ""),
+			column("balance", "NO", "", "decimal", "decimal(5,1) unsigned", "0.0", ""),
+			column("amount", "NO", "", "int", "int(11)", "", ""),
+			column("flags", "NO", "", "bit", "bit(8)", "", ""),
+			column("yr", "YES", "", "year", "year(4)", "", ""),
+			column("dur", "YES", "", "time", "time", "", ""),
+			column("born", "YES", "", "date", "date", "", ""),
+			column("created", "YES", "", "datetime", "datetime", "", ""),
+			column("seen", "NO", "", "timestamp", "timestamp", "CURRENT_TIMESTAMP", ""),
+			column("code", "YES", "MUL", "char", "char(2)", "", ""),
+			column("hash", "YES", "", "varbinary", "varbinary(16)", "", ""),
+			column("avatar", "YES", "", "blob", "blob", "", ""),
+			column("bio", "YES", "", "text", "text", "", ""),
+			column("doc", "YES", "", "json", "json", "", ""),
+		},
+		Indexes: []uniqueIndex{
+			{Name: "code_hash", Columns: []string{"code", "hash"}},
+			{Name: "email_UNIQUE", Columns: []string{"email"}},
+		},
+	},
+	"role": {
+		Columns: []tableObj{
+			column("id", "NO", "PRI", "int", "int(10) unsigned", "", "auto_increment"),
+			column("name", "NO", "UNI", "varchar", "varchar(45)", "", ""),
+		},
+		Indexes: []uniqueIndex{{Name: "name_UNIQUE", Columns: []string{"name"}}},
+	},
+	"user_role": {
+		Columns: []tableObj{
+			column("user_id", "NO", "PRI", "int", "int(11)", "", ""),
+			column("role_id", "NO", "PRI", "int", "int(10) unsigned", "", ""),
+			column("granted", "YES", "", "datetime", "datetime", "", ""),
+		},
+		ForeignKeys: []foreignKey{
+			{Column: "user_id", RefTable: "user", RefColumn: "id"},
+			{Column: "role_id", RefTable: "role", RefColumn: "id"},
+		},
+	},
+	"audit_log": {
+		Columns: []tableObj{
+			column("message", "NO", "", "varchar", "varchar(255)", "", ""),
+			column("logged", "NO", "", "datetime", "datetime", "CURRENT_TIMESTAMP", ""),
+		},
+	},
+	"post": {
+		Columns: []tableObj{
+			column("id", "NO", "PRI", "int", "int(11)", "", "auto_increment"),
+			column("title", "NO", "", "varchar", "varchar(100)", "", ""),
+			column("revision", "NO", "", "int", "int(10) unsigned", "0", ""),
+			column("deleted_at", "YES", "", "datetime", "datetime", "", ""),
+		},
+	},
+	"country": {
+		Columns: []tableObj{
+			column("code", "NO", "PRI", "char", "char(2)", "", ""),
+			column("name", "NO", "", "varchar", "varchar(60)", "", ""),
+		},
+	},
+	"active_user": {
+		Columns: []tableObj{
+			column("id", "NO", "", "int", "int(11)", "0", ""),
+			column("name", "NO", "", "varchar", "varchar(45)", "", ""),
+			column("roles", "NO", "", "bigint", "bigint(21)", "0", ""),
+		},
+		View: true,
+	},
+}
+
+// routines covers functions, procedures without OUT parameters or result set, and procedures with IN, OUT &
+// INOUT parameters, a result set or both. res & db are parameter names that clash with the generated code
+var routines = []routine{
+	{Name: "account_report", Type: "PROCEDURE", Params: []routineParam{
+		{Name: "account_id", Mode: "IN", DataType: "int", DTD: "int(11)"},
+		{Name: "balance", Mode: "OUT", DataType: "decimal", DTD: "decimal(10,2)"},
+	}},
+	{Name: "archive_orders", Type: "PROCEDURE", Params: []routineParam{
+		{Name: "before", Mode: "IN", DataType: "date", DTD: "date"},
+		{Name: "res", Mode: "IN", DataType: "varchar", DTD: "varchar(20)"},
+	}},
+	{Name: "order_count", Type: "FUNCTION", Params: []routineParam{
+		{Name: "account_id", Mode: "IN", DataType: "int", DTD: "int(11)"},
+	}, Returns: &routineParam{DataType: "int", DTD: "int(11)"}},
+	{Name: "order_notes", Type: "FUNCTION", Params: []routineParam{
+		{Name: "db", Mode: "IN", DataType: "int", DTD: "int(11)"},
+	}},
+	{Name: "order_totals", Type: "PROCEDURE", Params: []routineParam{
+		{Name: "account_id", Mode: "IN", DataType: "int", DTD: "int(10) unsigned"},
+		{Name: "since", Mode: "INOUT", DataType: "datetime", DTD: "datetime"},
+		{Name: "total", Mode: "OUT", DataType: "decimal", DTD: "decimal(10,2)"},
+		{Name: "orders", Mode: "OUT", DataType: "int", DTD: "int(11)"},
+	}},
+	{Name: "recent_orders", Type: "PROCEDURE", Params: []routineParam{
+		{Name: "account_id", Mode: "IN", DataType: "int", DTD: "int(11)"},
+		{Name: "type", Mode: "IN", DataType: "enum", DTD: "enum('web','store')"},
+	}},
+}
+
+// procResults are the result sets of the procedures that return rows
+var procResults = map[string][]ResultColumn{
+	"account_report": {
+		{Name: "month", Type: "char(7)"},
+		{Name: "spent", Type: "decimal(10,2)", Null: true},
+	},
+	"recent_orders": {
+		{Name: "id", Type: "int(11)"},
+		{Name: "total", Type: "decimal(10,2)", Null: true},
+		{Name: "created", Type: "datetime"},
+		{Name: "note", Type: "text", Null: true},
+	},
+}
+
+// queryFiles are the annotated .sql files of the fixtures, keyed by their path below the model directory. They
+// cover model & annotated results, parameters typed by columns & annotations, lists and quoted placeholders
+var queryFiles = map[string]string{
+	"User/users.sql": `-- Queries of the user table
+
+-- name: ActiveByEmail :many
+SELECT * FROM user WHERE status = :status AND email LIKE :email ORDER BY name
+
+-- name: ByIds :many
+-- param: ids []int(11)
+SELECT * FROM user WHERE id IN (:ids)
+
+-- name: OneByName :one
+SELECT * FROM user WHERE name = :name LIMIT 1;
+
+-- name: Rename :exec
+UPDATE user SET name = :name WHERE id = :id AND name <> ':kept'
+
+-- name: AgeStats :many
+-- param: min_age int(11)
+-- result: status, users bigint(21), average decimal(14,4) null
+SELECT status, COUNT(*) AS users, AVG(age) AS average
+FROM ` + "`user`" + `
+WHERE age >= :min_age
+GROUP BY status
+
+-- name: LastSeen :one
+-- result: id, seen, bio
+SELECT id, seen, bio FROM user WHERE id = :id
+`,
+}
+
+// features are the feature toggles of the fixtures
+var features = map[string]TableFeatures{
+	"post":        {SoftDelete: "deleted_at", Version: "revision"},
+	"country":     {ReadOnly: true},
+	"active_user": {Key: "id"},
+}
+
+// generate builds the connection package & the packages of every fixture into a fresh GOPATH and returns
+// its src directory. The options change the generator before it runs
+func generate(t *testing.T, options ...func(*Gostruct)) string {
+	gopath := t.TempDir()
+	prev := GOPATH
+	GOPATH = gopath
+	t.Cleanup(func() { GOPATH = prev })
+
+	g := newGenerator(gopath)
+	for _, option := range options {
+		option(&g)
+	}
+	err := os.MkdirAll(g.modelDir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	build(t, g)
+
+	return gopath + "/src"
+}
+
+// newGenerator returns the generator used for the fixtures
+func newGenerator(gopath string) Gostruct {
+	return Gostruct{
+		Database: "app",
+		Host:     "localhost",
+		Port:     "3306",
+		Username: "user",
+		Password: "secret",
+		modelDir: gopath + "/src/models",
+		dbDir:    "connection",
+		TypeOverrides: []TypeOverride{
+			{Column: "user.amount", GoType: "money.Amount", Import: "example.com/money"},
+			{Column: "country.code", GoType: "money.Amount", Import: "example.com/money"},
+		},
+		Features:    features,
+		ProcResults: procResults,
+		Connections: map[string]ConnectionConfig{
+			"app":     {Host: "localhost"},
+			"billing": {Host: "billing.internal", Port: "3307", Username: "billing", Password: "hunter2"},
+		},
+		errorChan: make(chan error, 100),
+	}
+}
+
+// build builds the connection package, the packages of every fixture along with their .sql files and the
+// procs package
+func build(t *testing.T, g Gostruct) {
+	err := g.buildConnectionPkg()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range queryFiles {
+		path := filepath.Join(g.modelDir, filepath.FromSlash(name))
+		err = os.MkdirAll(filepath.Dir(path), 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(contents), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := schemaSnapshot{Database: g.Database, Tables: fixtures}
+	for _, table := range snapshot.tableNames() {
+		err = g.buildTable(table, fixtures[table])
+		if err != nil {
+			t.Fatalf("%s: %v", table, err)
+		}
+	}
+
+	err = g.buildProcs(routines)
+	if err != nil {
+		t.Fatalf("procs: %v", err)
+	}
+
+	close(g.errorChan)
+	for err := range g.errorChan {
+		t.Error(err)
+	}
+}
+
+// generatedFiles returns the contents of every file below dir, keyed by the slash separated relative path
+func generatedFiles(t *testing.T, dir, suffix string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[strings.TrimSuffix(filepath.ToSlash(rel), suffix)] = string(contents)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+// TestGolden compares the generated files with the golden files in testdata/golden. Run the tests with
+// -update to refresh them after an intended change of the generated code, which also requires a new
+// templateVersion, so the manifest doesn't skip the packages generated by the previous template
+func TestGolden(t *testing.T) {
+	src := generate(t)
+	generated := generatedFiles(t, src, "")
+	for name := range queryFiles {
+		delete(generated, "models/"+name)
+	}
+
+	goldenDir := filepath.Join("testdata", "golden")
+	golden := generatedFiles(t, goldenDir, ".golden")
+	versionFile := filepath.Join("testdata", "template_version")
+	version, err := os.ReadFile(versionFile)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	if *update {
+		if strings.TrimSpace(string(version)) == templateVersion && !reflect.DeepEqual(golden, generated) {
+			t.Fatal("the generated code changed, bump templateVersion in manifest.go")
+		}
+
+		err := os.RemoveAll(goldenDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, contents := range generated {
+			path := filepath.Join(goldenDir, filepath.FromSlash(name)+".golden")
+			err = os.MkdirAll(filepath.Dir(path), 0777)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(path, []byte(contents), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = os.WriteFile(versionFile, []byte(templateVersion+"\n"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if strings.TrimSpace(string(version)) != templateVersion {
+		t.Errorf("the golden files were generated by template version %q, not %q, run the tests with -update", strings.TrimSpace(string(version)), templateVersion)
+	}
+	for name, want := range golden {
+		got, ok := generated[name]
+		if !ok {
+			t.Errorf("%s was not generated", name)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s differs from its golden file\n%s", name, firstDifference(got, want))
+		}
+	}
+	for name := range generated {
+		if _, ok := golden[name]; !ok {
+			t.Errorf("%s has no golden file, run the tests with -update", name)
+		}
+	}
+}
+
+// firstDifference describes the first line that differs between two files
+func firstDifference(got, want string) string {
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			return fmt.Sprintf("line %d:\n\tgot:  %q\n\twant: %q", i+1, g, w)
+		}
+	}
+	return ""
+}
+
+// sourceImporter type-checks packages from source. Packages are looked up in roots, which hold the
+// generated packages & the stubs of third-party packages, before the standard library
+type sourceImporter struct {
+	fset  *token.FileSet
+	roots []string
+	pkgs  map[string]*types.Package
+	std   types.Importer
+}
+
+// Import implements types.Importer
+func (imp *sourceImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.pkgs[path]; ok {
+		return pkg, nil
+	}
+
+	for _, root := range imp.roots {
+		dir := filepath.Join(root, filepath.FromSlash(path))
+		if !exists(dir) {
+			continue
+		}
+		files, _, _, err := imp.parseDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := imp.check(path, files)
+		if err != nil {
+			return nil, err
+		}
+		imp.pkgs[path] = pkg
+		return pkg, nil
+	}
+
+	return imp.std.Import(path)
+}
+
+// parseDir parses the Go files of a package, split into the package files, the tests in the package & the
+// tests in the external _test package
+func (imp *sourceImporter) parseDir(dir string) (files, tests, xtests []*ast.File, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		file, err := parser.ParseFile(imp.fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		switch {
+		case !strings.HasSuffix(name, "_test.go"):
+			files = append(files, file)
+		case strings.HasSuffix(file.Name.Name, "_test"):
+			xtests = append(xtests, file)
+		default:
+			tests = append(tests, file)
+		}
+	}
+
+	return files, tests, xtests, nil
+}
+
+// check type-checks the files of a single package
+func (imp *sourceImporter) check(path string, files []*ast.File) (*types.Package, error) {
+	conf := types.Config{Importer: imp}
+	return conf.Check(path, imp.fset, files, nil)
+}
+
+// TestGeneratedCodeCompiles type-checks every generated package, including its tests, against the stubs of
+// the third-party packages in testdata/stubs
+func TestGeneratedCodeCompiles(t *testing.T) {
+	for _, nameFuncs := range []bool{false, true} {
+		t.Run(fmt.Sprintf("nameFuncs=%v", nameFuncs), func(t *testing.T) {
+			src := generate(t, func(g *Gostruct) { g.NameFuncs = nameFuncs })
+			checkGenerated(t, src)
+		})
+	}
+}
+
+// checkGenerated type-checks every package below src, including its tests
+func checkGenerated(t *testing.T, src string) {
+	fset := token.NewFileSet()
+	imp := &sourceImporter{
+		fset:  fset,
+		roots: []string{src, filepath.Join("testdata", "stubs")},
+		pkgs:  map[string]*types.Package{},
+		std:   importer.Default(),
+	}
+
+	var dirs []string
+	for name := range generatedFiles(t, src, "") {
+		dir := filepath.Dir(filepath.FromSlash(name))
+		if !inArray(dir, dirs) {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		path := filepath.ToSlash(dir)
+		files, tests, xtests, err := imp.parseDir(filepath.Join(src, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = imp.Import(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if len(tests) > 0 {
+			_, err = imp.check(path, append(files, tests...))
+			if err != nil {
+				t.Errorf("%s tests: %v", path, err)
+			}
+		}
+		if len(xtests) > 0 {
+			_, err = imp.check(path+"_test", xtests)
+			if err != nil {
+				t.Errorf("%s_test: %v", path, err)
+			}
+		}
+	}
+}
+
+// runGenerated runs the tests of the generated packages that match run, along with the tests of testdata/tests
+// that belong to them. The generated packages are built in GOPATH mode against the stubs in testdata/stubs
+func runGenerated(t *testing.T, src, run string, pkgs ...string) {
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	// the stubs & tests are copied next to the generated packages, so testdata stays untouched
+	for _, dir := range []string{"stubs", "tests"} {
+		root := filepath.Join("testdata", dir)
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".go" {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			err = os.MkdirAll(filepath.Join(src, filepath.Dir(rel)), 0777)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(src, rel), contents, 0666)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	args := []string{"test", "-count=1", "-run", run}
+	for _, pkg := range pkgs {
+		args = append(args, "./"+pkg)
+	}
+	cmd := exec.Command(goCmd, args...)
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), "GOPATH="+filepath.Dir(src), "GO111MODULE=off", "GOFLAGS=", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
+
+// TestNullRoundTrip runs the round trip of every nullable type in the generated connection package through
+// its echo driver
+func TestNullRoundTrip(t *testing.T) {
+	runGenerated(t, generate(t), "TestNullRoundTrip", "connection")
+}
+
+// TestGeneratedRuntime runs every test & example of the generated packages, along with the tests of
+// testdata/tests against the generated save statements, fakes & connection runtime
+func TestGeneratedRuntime(t *testing.T) {
+	runGenerated(t, generate(t), ".", "connection", "models/...")
+}
+
+// TestDryRunAndDiff checks that dry-run & diff mode report the changes to the generated files without writing
+func TestDryRunAndDiff(t *testing.T) {
+	src := generate(t)
+
+	base := src + "/models/User/User_base.go"
+	contents, err := os.ReadFile(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(contents), "package User\n", "package User\n\n// edited\n", 1)
+	err = os.WriteFile(base, []byte(edited), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(src + "/models/Role/Role_fake.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(src+"/models/Role/Role_extended.go", []byte("package Role\n\n// custom\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := generatedFiles(t, src, "")
+
+	g := newGenerator(filepath.Dir(src))
+	g.DryRun, g.Diff = true, true
+	g.output = make(chan string)
+	done := make(chan string)
+	go func() {
+		var out string
+		for s := range g.output {
+			out += s
+		}
+		done <- out
+	}()
+	build(t, g)
+	close(g.output)
+	out := <-done
+
+	for _, want := range []string{
+		"modified  " + base + "\n",
+		"created   " + src + "/models/Role/Role_fake.go\n",
+		"unchanged " + src + "/models/Role/Role_extended.go\n",
+		"unchanged " + src + "/connection/connection.go\n",
+		"--- " + base + "\n+++ " + base + "\n@@ ",
+		" package User\n \n-// edited\n-\n import (\n",
+		"--- /dev/null\n+++ " + src + "/models/Role/Role_fake.go\n@@ -0,0 +1,",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Role_extended.go\n@@") {
+		t.Errorf("diff of a write-once file:\n%s", out)
+	}
+
+	after := generatedFiles(t, src, "")
+	if len(after) != len(before) {
+		t.Errorf("%d files after the dry run, want %d", len(after), len(before))
+	}
+	for name, contents := range before {
+		if after[name] != contents {
+			t.Errorf("%s was written", name)
+		}
+	}
+
+	// removing the last .sql file reports the deletion of the queries file, without deleting it
+	err = os.Remove(src + "/models/User/users.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := src + "/models/User/User_queries.go"
+	g = newGenerator(filepath.Dir(src))
+	g.DryRun, g.Diff = true, true
+	g.output = make(chan string, 1)
+	err = g.buildQueries("user", fixtures["user"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = <-g.output
+	for _, want := range []string{"deleted   " + queries + "\n", "--- " + queries + "\n+++ /dev/null\n@@ -1,"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out)
+		}
+	}
+	if !exists(queries) {
+		t.Errorf("%s was deleted", queries)
+	}
+}
+
+// TestVerify checks that verify detects the differences between a schema snapshot & the generated models
+func TestVerify(t *testing.T) {
+	src := generate(t)
+	g := newGenerator(filepath.Dir(src))
+
+	// the fingerprint has to survive a round trip through a snapshot file
+	schemaFile := filepath.Join(t.TempDir(), "schema.json")
+	err := saveSchema(schemaFile, schemaSnapshot{Database: g.Database, Tables: fixtures})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upToDate, err := g.verify(nil, schemaFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !upToDate {
+		t.Fatal("the models are stale right after generating them")
+	}
+
+	snapshot, err := loadSchema(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := snapshot.Tables["user"]
+	user.Columns[3].IsNullable = "NO"
+	user.Columns[4].IsBool = false
+	user.Columns[11].ColumnType = "bigint(21)"
+	user.Columns = append(user.Columns[:13], user.Columns[14:]...)
+	user.Columns = append(user.Columns, column("nickname", "YES", "", "varchar", "varchar(45)", "", ""))
+	snapshot.Tables["user"] = user
+	role := snapshot.Tables["role"]
+	role.Indexes = nil
+	snapshot.Tables["role"] = role
+	country := snapshot.Tables["country"]
+	country.Columns[0], country.Columns[1] = country.Columns[1], country.Columns[0]
+	snapshot.Tables["country"] = country
+	userRole := snapshot.Tables["user_role"]
+	userRole.ForeignKeys = userRole.ForeignKeys[:1]
+	snapshot.Tables["user_role"] = userRole
+	activeUser := snapshot.Tables["active_user"]
+	activeUser.View = false
+	snapshot.Tables["active_user"] = activeUser
+	post := snapshot.Tables["post"]
+	post.Columns[1].CharMaxLength.Int64++
+	snapshot.Tables["post"] = post
+	delete(snapshot.Tables, "audit_log")
+	snapshot.Tables["orders"] = tableSchema{Columns: []tableObj{column("id", "NO", "PRI", "int", "int(11)", "", "")}}
+
+	tests := map[string][]string{
+		"user": {
+			`column age: null is "YES" in the model, "NO" in the schema`,
+			`column age: Go type is "*int64" in the model, "int64" in the schema`,
+			`column active: Go type is "bool" in the model, "int64" in the schema`,
+			`column big: type is "bigint(20)" in the model, "bigint(21)" in the schema`,
+			"column nickname is missing from the model",
+			"column umed no longer exists",
+		},
+		"role":        {"the unique indexes differ from the schema"},
+		"country":     {"the column order differs from the schema"},
+		"user_role":   {"the foreign keys differ from the schema"},
+		"active_user": {"the model was generated from a view, the schema holds a table"},
+		"post":        {"the schema fingerprint differs from the model"},
+		"orders":      {"the model has not been generated"},
+	}
+	for table, want := range tests {
+		got, err := g.verifyTable(table, snapshot.Tables[table])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", table, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+// TestRunFinishesAfterError checks that a table that fails to generate is reported & counted as done, so the
+// rest of the run finishes
+func TestRunFinishesAfterError(t *testing.T) {
+	gopath := t.TempDir()
+	prev := GOPATH
+	GOPATH = gopath
+	t.Cleanup(func() { GOPATH = prev })
+
+	g := newGenerator(gopath)
+	g.Features = map[string]TableFeatures{"post": {SoftDelete: "title"}}
+	g.add, g.errorChan, g.totalChan = make(chan int, 1), make(chan error, 1), make(chan int, 1)
+	g.output, g.hashChan = make(chan string), make(chan tableHash)
+	g.generated = map[string]string{}
+	err := os.MkdirAll(g.modelDir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker := g
+	go g.handler()
+
+	wg.Add(2)
+	go worker.runTable("post", fixtures["post"])
+	go worker.runTable("role", fixtures["role"])
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the run didn't finish after a table failed")
+	}
+
+	if g.processed != 2 || g.errored != 1 {
+		t.Fatalf("processed %d tables with %d errors, want 2 with 1 error", g.processed, g.errored)
+	}
+	if want := "post: config error: soft delete column post.title"; !strings.HasPrefix(g.errors[0].Error(), want) {
+		t.Errorf("got %v, want %q", g.errors[0], want)
+	}
+	if _, ok := g.generated["role"]; !ok {
+		t.Error("role wasn't generated")
+	}
+}
+
+// TestJoinFile checks that the join helpers are only generated while the packages of both linked tables are,
+// and removed once the table stops being a join table
+func TestJoinFile(t *testing.T) {
+	gopath := t.TempDir()
+	prev := GOPATH
+	GOPATH = gopath
+	t.Cleanup(func() { GOPATH = prev })
+
+	g := newGenerator(gopath)
+	err := os.MkdirAll(g.modelDir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joinFile := g.modelDir + "/User_role/User_role_join.go"
+
+	err = g.buildTable("user_role", fixtures["user_role"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists(joinFile) {
+		t.Error("the join helpers were generated without the role & user packages")
+	}
+	unlinked := g.tableHash("user_role", fixtures["user_role"])
+
+	g.selected = map[string]bool{"role": true, "user": true, "user_role": true}
+	if g.tableHash("user_role", fixtures["user_role"]) == unlinked {
+		t.Error("the hash of user_role didn't change once the role & user packages are generated")
+	}
+	err = g.buildTable("user_role", fixtures["user_role"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists(joinFile) {
+		t.Fatal("the join helpers weren't generated along with the role & user packages")
+	}
+
+	schema := fixtures["user_role"]
+	schema.ForeignKeys = nil
+	err = g.buildTable("user_role", schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists(joinFile) {
+		t.Error("the join helpers weren't removed once user_role stopped being a join table")
+	}
+}
+
+// TestOldConnectionFile checks that a connection.go of an older gostruct version stops the generation instead
+// of leaving a connection package that doesn't compile
+func TestOldConnectionFile(t *testing.T) {
+	src := generate(t)
+	g := newGenerator(filepath.Dir(src))
+
+	path := src + "/connection/connection.go"
+	err := os.WriteFile(path, []byte("package connection\n\nfunc GetConnection() {}\n\nfunc BuildQuery() {}\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.buildConnectionPkg()
+	if err == nil || !strings.Contains(err.Error(), "connection.go was generated by an older version of gostruct") {
+		t.Errorf("got %v, want an error about the old connection.go", err)
+	}
+}
+
+// TestNameClashes checks that tables whose name or columns would declare a generated name twice are rejected
+func TestNameClashes(t *testing.T) {
+	role := fixtures["role"].Columns
+	for _, test := range []struct {
+		table  string
+		schema tableSchema
+		name   string
+	}{
+		{"repository", tableSchema{Columns: role}, "repository"},
+		{"scan", tableSchema{Columns: role}, "scan"},
+		{"user", tableSchema{Columns: append(role[:len(role):len(role)], column("repository", "NO", "", "enum", "enum('a','b')", "a", ""))}, "UserRepository"},
+		{"fake", tableSchema{Columns: role}, "Fake"},
+		{"user_link", tableSchema{
+			Columns: []tableObj{
+				column("user_id", "NO", "PRI", "int", "int(11)", "", ""),
+				column("link_id", "NO", "PRI", "int", "int(11)", "", ""),
+			},
+			ForeignKeys: []foreignKey{
+				{Column: "user_id", RefTable: "user", RefColumn: "id"},
+				{Column: "link_id", RefTable: "link", RefColumn: "id"},
+			},
+		}, "Links"},
+		{"repositories", tableSchema{Columns: role}, ""},
+	} {
+		g := newGenerator(t.TempDir())
+		err := os.MkdirAll(g.modelDir, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = g.buildTable(test.table, test.schema)
+		want := "name error: the generated code declares " + test.name + " twice, rename the table or the column it comes from"
+		if test.name != "" && (err == nil || err.Error() != want) {
+			t.Errorf("%s: got %v, want %q", test.table, err, want)
+		}
+		if test.name == "" && err != nil {
+			t.Errorf("%s: got %v", test.table, err)
+		}
+	}
+}
+
+// TestManifest checks that only tables whose hash changed since they were generated are regenerated
+func TestManifest(t *testing.T) {
+	src := generate(t)
+	g := newGenerator(filepath.Dir(src))
+
+	hash := g.tableHash("user", fixtures["user"])
+	g.generated = map[string]string{"user": hash}
+	err := g.saveManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.hashes, err = g.loadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !g.upToDate("user", hash) {
+		t.Error("user isn't up to date after it was generated")
+	}
+	if g.upToDate("role", g.tableHash("role", fixtures["role"])) {
+		t.Error("role is up to date without a hash in the manifest")
+	}
+
+	changed := fixtures["user"]
+	changed.Indexes = nil
+	if g.upToDate("user", g.tableHash("user", changed)) {
+		t.Error("user is up to date after its schema changed")
+	}
+
+	renamed := g
+	renamed.NameFuncs = true
+	if renamed.upToDate("user", renamed.tableHash("user", fixtures["user"])) {
+		t.Error("user is up to date after the options changed")
+	}
+
+	toggled := g
+	toggled.Features = map[string]TableFeatures{"user": {ReadOnly: true}}
+	if toggled.upToDate("user", toggled.tableHash("user", fixtures["user"])) {
+		t.Error("user is up to date after its features changed")
+	}
+
+	forced := g
+	forced.Force = true
+	if forced.upToDate("user", hash) {
+		t.Error("user is up to date with -force")
+	}
+
+	err = os.Remove(src + "/models/User/User_base.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.upToDate("user", hash) {
+		t.Error("user is up to date after its package was removed")
+	}
+}
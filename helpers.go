@@ -1,11 +1,10 @@
 package gostruct
 
 import (
-	"bytes"
 	"os"
-	"os/exec"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 //exists checks if path or file exists
@@ -23,12 +22,14 @@ func uppercaseFirst(s string) string {
 		return strings.ToLower(s)
 	}
 
-	bts := []byte(s)
-
-	lc := bytes.ToUpper([]byte{bts[0]})
-	rest := bts[1:]
+	first, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(first)) + s[size:]
+}
 
-	return string(bytes.Join([][]byte{lc, rest}, nil))
+// lowercaseFirst lowercases the first character of a Go name
+func lowercaseFirst(s string) string {
+	first, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(first)) + s[size:]
 }
 
 // createDirectory creates directory and sets permissions to 0777
@@ -68,46 +69,6 @@ func writeFile(path, contents string, overwrite bool) error {
 	return nil
 }
 
-// runCommand runs cli commands
-func runCommand(command string) (string, error) {
-	parts := getCmdParts(command)
-	cmd := exec.Command(parts[0], parts[1:]...)
-	err := cmd.Run()
-	if err != nil {
-		return "", err
-	}
-
-	return "", nil
-}
-
-// getCmdParts normalizes command into a string array
-func getCmdParts(command string) []string {
-	lastQuote := rune(0)
-	f := func(c rune) bool {
-		switch {
-		case c == lastQuote:
-			lastQuote = rune(0)
-			return false
-		case lastQuote != rune(0):
-			return false
-		case unicode.In(c, unicode.Quotation_Mark):
-			lastQuote = c
-			return false
-		default:
-			return unicode.IsSpace(c)
-		}
-	}
-
-	var parts []string
-	preParts := strings.FieldsFunc(command, f)
-	for i := range preParts {
-		part := preParts[i]
-		parts = append(parts, strings.Replace(part, "'", "", -1))
-	}
-
-	return parts
-}
-
 // inArray determines if string is in array
 func inArray(char string, strings []string) bool {
 	for _, a := range strings {
@@ -117,3 +78,22 @@ func inArray(char string, strings []string) bool {
 	}
 	return false
 }
+
+// pluralize returns a naive English plural of a name, used for naming relationship helpers
+func pluralize(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	case strings.HasSuffix(lower, "y") && len(s) > 1 && !strings.ContainsAny(lower[len(lower)-2:len(lower)-1], "aeiou"):
+		return s[:len(s)-1] + "ies"
+	}
+
+	return s + "s"
+}
+
+// modelImport returns the import path of the generated package for a table
+func (g Gostruct) modelImport(table string) string {
+	return strings.Replace(g.modelDir, GOPATH+"/src/", "", 1) + "/" + uppercaseFirst(table)
+}
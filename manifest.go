@@ -0,0 +1,101 @@
+package gostruct
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+)
+
+const (
+	// Version is the version of the generator
+	Version = "1.1.0"
+
+	// templateVersion has to change with every change of the generated code, so packages generated by an
+	// older template are regenerated even when their table didn't change
+	templateVersion = "21"
+
+	// manifestFile is the name of the manifest in the model directory
+	manifestFile = "gostruct-manifest.json"
+)
+
+// manifest records the hash of every generated table, so tables that didn't change can be skipped
+type manifest struct {
+	Version string            `json:"version"`
+	Tables  map[string]string `json:"tables"`
+}
+
+// tableHash is the hash of a table that was generated
+type tableHash struct {
+	Table string
+	Hash  string
+}
+
+// tableHash returns a hash of everything the package of a table is generated from: its schema, the generator
+// & template versions, the options & features that affect the generated code, its annotated .sql files and
+// whether its join helpers are generated
+func (g Gostruct) tableHash(table string, schema tableSchema) string {
+	data, _ := json.Marshal(struct {
+		Fingerprint     string
+		Version         string
+		TemplateVersion string
+		Database        string
+		DBDir           string
+		NameFuncs       bool
+		TypeOverrides   []TypeOverride
+		Features        TableFeatures
+		Queries         string
+		JoinLinked      bool
+	}{schema.fingerprint(), Version, templateVersion, g.Database, g.dbDir, g.NameFuncs, g.TypeOverrides, g.Features[table], g.querySource(table),
+		g.joinLinked(schema)})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// upToDate reports whether the package of a table was generated from the same hash & still exists
+func (g Gostruct) upToDate(table, hash string) bool {
+	tableNaming := uppercaseFirst(table)
+	return !g.Force && g.hashes[table] == hash && exists(g.modelDir+"/"+tableNaming+"/"+tableNaming+"_base.go")
+}
+
+// loadManifest reads the table hashes of the manifest in the model directory. A missing manifest is empty
+func (g Gostruct) loadManifest() (map[string]string, error) {
+	data, err := os.ReadFile(g.modelDir + "/" + manifestFile)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Tables == nil {
+		m.Tables = map[string]string{}
+	}
+
+	return m.Tables, nil
+}
+
+// saveManifest writes the hashes of the tables that were generated to the manifest, along with the hashes
+// of the tables that weren't part of this run
+func (g Gostruct) saveManifest() error {
+	m := manifest{Version: Version, Tables: map[string]string{}}
+	for table, hash := range g.hashes {
+		m.Tables[table] = hash
+	}
+	for table, hash := range g.generated {
+		m.Tables[table] = hash
+	}
+
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(g.modelDir+"/"+manifestFile, string(data)+"\n", true)
+}
@@ -0,0 +1,78 @@
+package gostruct
+
+import (
+	"errors"
+	"strings"
+)
+
+// baseNames returns the package level names the base file & the tests of a table declare. Some of them come from
+// the schema, such as the nilable structure named after the table and the enum & set types named after their
+// columns, so they can clash with the names of the generated code
+func (g Gostruct) baseNames(table string, schema tableSchema) []string {
+	tableNaming := uppercaseFirst(table)
+	funcName := ""
+	if g.NameFuncs {
+		funcName = tableNaming
+	}
+
+	names := []string{tableNaming, strings.ToLower(table), tableNaming + "Repository", "NewRepository", "repository",
+		"defaultRepository", "Read" + funcName + "ByKey", "ReadAll" + funcName, "Read" + funcName + "ByQuery",
+		"ReadOne" + funcName + "ByQuery", "Stream" + funcName + "ByQuery", funcName + "Exec", "scan", "columnFields",
+		"selectQuery", "liveQuery", "SelectQuery", "saveQuery", "deleteQuery", "saveColumns", "saveUpdates",
+		"testRepository", "testRecord"}
+	keys := 0
+	for _, object := range schema.Columns {
+		if object.DataType == "enum" || object.DataType == "set" {
+			typeName := enumTypeName(table, object.Name)
+			names = append(names, typeName)
+			for _, ident := range enumIdentifiers(enumValues(object.ColumnType)) {
+				names = append(names, typeName+ident)
+			}
+			if object.DataType == "set" {
+				names = append(names, lowercaseFirst(typeName)+"Members")
+			}
+		}
+		if g.Features[table].isKey(object) {
+			keys++
+		}
+	}
+	if keys > 1 {
+		names = append(names, tableNaming+"Key")
+	}
+
+	return names
+}
+
+// fakeNames returns the package level names the fake file of a table declares
+func fakeNames() []string {
+	return []string{"Fake", "NewFake"}
+}
+
+// joinNames returns the package level names the join file of a join table declares
+func joinNames(jt joinTable) []string {
+	names := []string{"Links", "NewLinks", "defaultLinks"}
+	for _, related := range []string{jt.Left.RefTable, jt.Right.RefTable} {
+		single := uppercaseFirst(related)
+		plural := pluralize(single)
+		names = append(names, plural, "Add"+single, "Remove"+single, "Set"+plural)
+	}
+	return names
+}
+
+// checkNames returns an error when the generated code of a table would declare a name twice, which happens when
+// the name of the table or one of its columns matches a name of the generated code
+func (g Gostruct) checkNames(table string, schema tableSchema) error {
+	declared := map[string]bool{}
+	names := append(g.baseNames(table, schema), fakeNames()...)
+	if jt, ok := detectJoinTable(schema.Columns, schema.ForeignKeys); ok && !g.Features[table].ReadOnly && !schema.View {
+		names = append(names, joinNames(jt)...)
+	}
+	for _, name := range names {
+		if declared[name] {
+			return errors.New("name error: the generated code declares " + name + " twice, rename the table or the column it comes from")
+		}
+		declared[name] = true
+	}
+
+	return nil
+}
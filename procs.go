@@ -0,0 +1,414 @@
+package gostruct
+
+import (
+	"database/sql"
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// routine is a stored procedure or function of the database
+type routine struct {
+	Name string
+	// Type is PROCEDURE or FUNCTION
+	Type   string
+	Params []routineParam
+	// Returns is the result of a function
+	Returns *routineParam
+}
+
+// routineParam is a parameter of a stored procedure or function, or the result of a function
+type routineParam struct {
+	Name string
+	// Mode is IN, OUT or INOUT, and empty for the result of a function
+	Mode     string
+	DataType string
+	// DTD is the full type of the parameter, e.g. decimal(10,2) unsigned
+	DTD string
+}
+
+// procParamNames are the names of the locals, helpers & packages of the generated functions, which parameters
+// are renamed to avoid
+var procParamNames = []string{"ctx", "con", "err", "res", "rows", "objects", "obj", "out", "result", "conn", "context", "db", "errors", "sql"}
+
+// getRoutines returns the stored procedures & functions of a database along with their parameters
+func getRoutines(con *sql.DB, database string) ([]routine, error) {
+	rows, err := con.Query("SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? ORDER BY ROUTINE_NAME", database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routines []routine
+	index := map[string]int{}
+	for rows.Next() {
+		var r routine
+		err = rows.Scan(&r.Name, &r.Type)
+		if err != nil {
+			return nil, err
+		}
+		index[r.Type+" "+r.Name] = len(routines)
+		routines = append(routines, r)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := con.Query("SELECT SPECIFIC_NAME, ROUTINE_TYPE, IFNULL(PARAMETER_MODE, ''), IFNULL(PARAMETER_NAME, ''), DATA_TYPE, DTD_IDENTIFIER FROM information_schema.PARAMETERS WHERE SPECIFIC_SCHEMA = ? ORDER BY SPECIFIC_NAME, ORDINAL_POSITION", database)
+	if err != nil {
+		return nil, err
+	}
+	defer params.Close()
+
+	for params.Next() {
+		var name, routineType string
+		var p routineParam
+		err = params.Scan(&name, &routineType, &p.Mode, &p.Name, &p.DataType, &p.DTD)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[routineType+" "+name]
+		if !ok {
+			continue
+		}
+		if p.Mode == "" {
+			// the result of a function has ordinal position 0 & no mode
+			result := p
+			routines[i].Returns = &result
+		} else {
+			routines[i].Params = append(routines[i].Params, p)
+		}
+	}
+
+	return routines, params.Err()
+}
+
+// RunProcs generates the procs package for the stored procedures & functions in a specific database and host
+func (g Gostruct) RunProcs() error {
+	con, err := getConnection(g)
+	if err != nil {
+		return err
+	}
+	defer con.Close()
+
+	routines, err := getRoutines(con, g.Database)
+	if err != nil {
+		return err
+	}
+
+	return g.buildProcs(routines)
+}
+
+// paramType returns the Go types of a parameter, result or result set column. Enum & set parameters are strings
+func (g Gostruct) paramType(routineName, name, dataType, dtd string, nullable bool) fieldType {
+	object := tableObj{Name: name, DataType: dataType, ColumnType: dtd, IsNullable: "NO"}
+	if nullable {
+		object.IsNullable = "YES"
+	}
+	if dataType == "enum" || dataType == "set" {
+		object.DataType = "varchar"
+	}
+
+	t, _ := g.columnType(routineName, object)
+	return t
+}
+
+// resultColumn returns the data type & the column type of a configured result set column
+func resultColumn(c ResultColumn) (string, string) {
+	columnType := strings.ToLower(strings.TrimSpace(c.Type))
+	dataType := columnType
+	if i := strings.IndexAny(dataType, "( "); i >= 0 {
+		dataType = dataType[:i]
+	}
+	return dataType, columnType
+}
+
+// procParam returns the Go name of a parameter
+func procParam(name string) string {
+	if token.IsKeyword(name) || inArray(name, procParamNames) {
+		return name + "Param"
+	}
+	return name
+}
+
+// buildProcs builds the procs package with a typed function for every stored procedure & function. OUT &
+// INOUT parameters are passed through session variables, which are read on the connection the procedure ran on
+func (g Gostruct) buildProcs(routines []routine) error {
+	dir := g.procsDir
+	if dir == "" {
+		dir = g.modelDir + "/procs"
+	}
+	if !exists(dir) && !g.preview() {
+		err := createDirectory(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	imports := map[string]string{
+		g.dbDir:                    "db",
+		"database/sql":             "",
+		"github.com/pkg/errors":    "",
+		"golang.org/x/net/context": "",
+	}
+
+	contents := `
+
+// conn returns a single connection of the shared pool, so the session variables holding the OUT parameters of
+// a procedure are read on the connection it ran on
+func conn(ctx context.Context) (*sql.Conn, error) {
+	con, err := db.Get("` + g.Database + `")
+	if err != nil {
+		return nil, errors.Wrap(err, "connection failed")
+	}
+
+	return con.Conn(ctx)
+}`
+
+	for _, r := range routines {
+		var body string
+		if r.Type == "FUNCTION" {
+			body = g.buildFunction(r, imports)
+		} else {
+			body = g.buildProcedure(r, imports)
+		}
+		contents += body
+	}
+
+	contents = `// Package procs contains typed wrappers for the stored procedures & functions of the ` + g.Database + ` database
+package procs` + importBlock(imports) + contents + "\n"
+
+	return g.writeGoFile(dir+"/procs.go", contents, true)
+}
+
+// buildFunction returns the wrapper of a stored function, which selects its result
+func (g Gostruct) buildFunction(r routine, imports map[string]string) string {
+	name := uppercaseFirst(r.Name)
+
+	var params, args, marks []string
+	for _, p := range r.Params {
+		t := g.paramType(r.Name, p.Name, p.DataType, p.DTD, false)
+		for path, alias := range t.Imports {
+			imports[path] = alias
+		}
+		params = append(params, procParam(p.Name)+" "+t.Type)
+		args = append(args, procParam(p.Name))
+		marks = append(marks, "?")
+	}
+
+	result := fieldType{Type: "[]byte", NilType: "[]byte", Convert: "%s"}
+	if r.Returns != nil {
+		result = g.paramType(r.Name, "result", r.Returns.DataType, r.Returns.DTD, true)
+		for path, alias := range result.Imports {
+			imports[path] = alias
+		}
+	}
+
+	return `
+
+// ` + name + ` calls the ` + r.Name + ` stored function
+func ` + name + `(` + strings.Join(append([]string{"ctx context.Context"}, params...), ", ") + `) (` + result.Type + `, error) {
+	con, err := db.Get("` + g.Database + `")
+	if err != nil {
+		return nil, errors.Wrap(err, "connection failed")
+	}
+
+	var result ` + result.NilType + `
+	err = con.QueryRowContext(` + strings.Join(append([]string{`ctx, "SELECT ` + r.Name + `(` + strings.Join(marks, ", ") + `)"`}, args...), ", ") + `).Scan(&result)
+	if err != nil {
+		return nil, errors.Wrap(db.ClassifyError(err), "call failed for ` + r.Name + `")
+	}
+
+	return ` + fmt.Sprintf(result.Convert, "result") + `, nil
+}`
+}
+
+// buildProcedure returns the wrapper of a stored procedure along with the structures of its OUT parameters &
+// result set. The result set is scanned when its columns are configured
+func (g Gostruct) buildProcedure(r routine, imports map[string]string) string {
+	name := uppercaseFirst(r.Name)
+	lower := strings.ToLower(r.Name)
+	columns := g.ProcResults[r.Name]
+
+	var params, callArgs, marks, sets, outVars, outFields, outNilFields, outDest, outValues []string
+	for _, p := range r.Params {
+		param := procParam(p.Name)
+		variable := "@" + p.Name
+
+		if p.Mode != "OUT" {
+			t := g.paramType(r.Name, p.Name, p.DataType, p.DTD, false)
+			for path, alias := range t.Imports {
+				imports[path] = alias
+			}
+			params = append(params, param+" "+t.Type)
+		}
+
+		switch p.Mode {
+		case "IN":
+			marks = append(marks, "?")
+			callArgs = append(callArgs, param)
+			continue
+		case "INOUT":
+			sets = append(sets, `
+	_, err = con.ExecContext(ctx, "SET `+variable+` = ?", `+param+`)
+	if err != nil {
+		return `+"%s"+`errors.Wrap(db.ClassifyError(err), "call failed for `+r.Name+`")
+	}
+`)
+		}
+
+		// OUT & INOUT parameters are read back from their session variables after the call
+		t := g.paramType(r.Name, p.Name, p.DataType, p.DTD, true)
+		for path, alias := range t.Imports {
+			imports[path] = alias
+		}
+		field := uppercaseFirst(p.Name)
+		marks = append(marks, variable)
+		outVars = append(outVars, variable)
+		outFields = append(outFields, "\n\t"+field+"\t"+t.Type)
+		outNilFields = append(outNilFields, "\n\t"+field+"\t"+t.NilType)
+		outDest = append(outDest, "&out."+field)
+		outValues = append(outValues, fmt.Sprintf(t.Convert, "out."+field))
+	}
+
+	var rowFields, rowNilFields, rowDest, rowValues []string
+	for _, c := range columns {
+		dataType, columnType := resultColumn(c)
+		t := g.paramType(r.Name, c.Name, dataType, columnType, c.Null)
+		for path, alias := range t.Imports {
+			imports[path] = alias
+		}
+		field := uppercaseFirst(c.Name)
+		rowFields = append(rowFields, "\n\t"+field+"\t"+t.Type+"\t`column:\""+c.Name+"\"`")
+		rowNilFields = append(rowNilFields, "\n\t"+field+"\t"+t.NilType)
+		rowDest = append(rowDest, "&obj."+field)
+		rowValues = append(rowValues, fmt.Sprintf(t.Convert, "obj."+field))
+	}
+
+	var types, returns, zero string
+	switch {
+	case len(columns) > 0 && len(outVars) > 0:
+		returns, zero = "([]*"+name+"Row, *"+name+"Out, error)", "nil, nil, "
+	case len(columns) > 0:
+		returns, zero = "([]*"+name+"Row, error)", "nil, "
+	case len(outVars) > 0:
+		returns, zero = "(*"+name+"Out, error)", "nil, "
+	default:
+		returns, zero = "(sql.Result, error)", "nil, "
+	}
+
+	if len(columns) > 0 {
+		types += `
+
+// ` + name + `Row is a row of the result set of the ` + r.Name + ` stored procedure
+type ` + name + `Row struct {` + strings.Join(rowFields, "") + `
+}
+
+// ` + lower + `Row is the nilable structure the rows of the result set are scanned into
+type ` + lower + `Row struct {` + strings.Join(rowNilFields, "") + `
+}`
+	}
+	if len(outVars) > 0 {
+		types += `
+
+// ` + name + `Out holds the OUT & INOUT parameters of the ` + r.Name + ` stored procedure
+type ` + name + `Out struct {` + strings.Join(outFields, "") + `
+}
+
+// ` + lower + `Out is the nilable structure the OUT & INOUT parameters are scanned into
+type ` + lower + `Out struct {` + strings.Join(outNilFields, "") + `
+}`
+	}
+
+	call := `"CALL ` + r.Name + `(` + strings.Join(marks, ", ") + `)"`
+	callExpr := strings.Join(append([]string{"ctx, " + call}, callArgs...), ", ")
+	wrapErr := `errors.Wrap(db.ClassifyError(err), "call failed for ` + r.Name + `")`
+
+	body := `
+
+// ` + name + ` calls the ` + r.Name + ` stored procedure
+func ` + name + `(` + strings.Join(append([]string{"ctx context.Context"}, params...), ", ") + `) ` + returns + ` {`
+
+	if len(columns) == 0 && len(outVars) == 0 {
+		return types + body + `
+	con, err := db.Get("` + g.Database + `")
+	if err != nil {
+		return nil, errors.Wrap(err, "connection failed")
+	}
+
+	res, err := con.ExecContext(` + callExpr + `)
+	if err != nil {
+		return res, ` + wrapErr + `
+	}
+
+	return res, nil
+}`
+	}
+
+	body += `
+	con, err := conn(ctx)
+	if err != nil {
+		return ` + zero + `err
+	}
+	defer con.Close()
+`
+	for _, set := range sets {
+		body += fmt.Sprintf(set, zero)
+	}
+
+	var results []string
+	if len(columns) > 0 {
+		body += `
+	rows, err := con.QueryContext(` + callExpr + `)
+	if err != nil {
+		return ` + zero + wrapErr + `
+	}
+	defer rows.Close()
+
+	var objects []*` + name + `Row
+	for rows.Next() {
+		var obj ` + lower + `Row
+		err = rows.Scan(` + strings.Join(rowDest, ", ") + `)
+		if err != nil {
+			return ` + zero + `errors.Wrap(err, "scan failed for ` + r.Name + `")
+		}
+		objects = append(objects, &` + name + `Row{` + strings.Join(rowValues, ", ") + `})
+	}
+	err = rows.Err()
+	if err != nil {
+		return ` + zero + wrapErr + `
+	}
+`
+		results = append(results, "objects")
+		if len(outVars) > 0 {
+			body += `
+	// the session variables are only set once the result set was read
+	rows.Close()
+`
+		}
+	} else {
+		body += `
+	_, err = con.ExecContext(` + callExpr + `)
+	if err != nil {
+		return ` + zero + wrapErr + `
+	}
+`
+	}
+
+	if len(outVars) > 0 {
+		body += `
+	var out ` + lower + `Out
+	err = con.QueryRowContext(ctx, "SELECT ` + strings.Join(outVars, ", ") + `").Scan(` + strings.Join(outDest, ", ") + `)
+	if err != nil {
+		return ` + zero + `errors.Wrap(err, "reading the OUT parameters failed for ` + r.Name + `")
+	}
+`
+		results = append(results, "&"+name+"Out{"+strings.Join(outValues, ", ")+"}")
+	}
+
+	return types + body + `
+	return ` + strings.Join(results, ", ") + `, nil
+}`
+}
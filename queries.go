@@ -0,0 +1,505 @@
+package gostruct
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// query is a named query of an annotated .sql file in the directory of a table package:
+//
+//	-- name: ActiveByAccount :many
+//	-- param: ids []int(11)
+//	-- result: id, total decimal(10,2) null
+//	SELECT id, total FROM orders WHERE account_id = :account_id AND id IN (:ids)
+type query struct {
+	Name string
+	// Kind is :many, :one or :exec
+	Kind string
+	SQL  string
+	// Params are the names of the :name placeholders, in the order they first appear
+	Params []string
+	// ParamTypes holds the column types of the -- param annotations by name. List types start with []
+	ParamTypes map[string]string
+	// Result holds the columns of the -- result annotation, which replace the model as the result
+	Result []ResultColumn
+	// File is the name of the file the query was read from
+	File string
+	// Line is the line of the -- name: annotation
+	Line int
+}
+
+// rowType returns the name of the row structure of a query with a -- result annotation
+func (q query) rowType() string {
+	return q.Name + "Row"
+}
+
+// nilRowType returns the name of the nilable structure the rows of a query with a -- result annotation are
+// scanned into
+func (q query) nilRowType() string {
+	return lowercaseFirst(q.Name) + "Row"
+}
+
+// constName returns the name of the constant that holds the statement of a query
+func (q query) constName() string {
+	return lowercaseFirst(q.Name) + "Query"
+}
+
+// queryKinds are the kinds of queries: a list of records, a single record, or a statement without results
+var queryKinds = []string{":many", ":one", ":exec"}
+
+// queryLocals are the names of the locals & packages of the generated functions, which parameters are renamed to
+// avoid
+var queryLocals = []string{"ctx", "err", "rows", "objects", "obj", "context", "db", "errors", "sql"}
+
+// generatedNames returns the package level names of the generated code of a table, which named queries can't
+// use, including the unexported ones the constants & nilable row structures of the queries could shadow.
+// Delete, Live, Save & Select would clash with the constants of the base file
+func (g Gostruct) generatedNames(table string, schema tableSchema) []string {
+	names := append([]string{"Delete", "Live", "Save", "Select"}, g.baseNames(table, schema)...)
+	names = append(names, fakeNames()...)
+	if jt, ok := detectJoinTable(schema.Columns, schema.ForeignKeys); ok && !g.Features[table].ReadOnly && !schema.View {
+		names = append(names, joinNames(jt)...)
+	}
+	return names
+}
+
+// queryFiles returns the .sql files in the directory of a table package
+func (g Gostruct) queryFiles(table string) ([]string, error) {
+	return filepath.Glob(g.modelDir + "/" + uppercaseFirst(table) + "/*.sql")
+}
+
+// querySource returns the contents of the .sql files of a table, so the manifest notices when they change
+func (g Gostruct) querySource(table string) string {
+	files, _ := g.queryFiles(table)
+
+	var source string
+	for _, file := range files {
+		data, _ := os.ReadFile(file)
+		source += filepath.Base(file) + "\n" + string(data)
+	}
+	return source
+}
+
+// parseQueries reads the named queries of a .sql file. Every query starts with a -- name: annotation, which
+// may be followed by -- param: & -- result: annotations. Lines before the first query are ignored
+func parseQueries(file, contents string) ([]query, error) {
+	fail := func(format string, args ...interface{}) ([]query, error) {
+		return nil, errors.New("query error: " + file + ": " + fmt.Sprintf(format, args...))
+	}
+
+	var queries []query
+	var q *query
+	var lines []string
+	finish := func() error {
+		if q == nil {
+			return nil
+		}
+		q.SQL = strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";")
+		if q.SQL == "" {
+			return errors.New("query error: " + file + ": " + q.Name + " has no statement")
+		}
+
+		params, positional := queryParams(q.SQL)
+		if positional {
+			return errors.New("query error: " + file + ": " + q.Name + " uses ? placeholders, name them like :id instead")
+		}
+		for name := range q.ParamTypes {
+			if !inArray(name, params) {
+				return errors.New("query error: " + file + ": " + q.Name + " has no :" + name + " parameter")
+			}
+		}
+		q.Params = params
+
+		queries = append(queries, *q)
+		q, lines = nil, nil
+		return nil
+	}
+
+	for i, line := range strings.Split(contents, "\n") {
+		annotation, value, ok := queryAnnotation(line)
+		switch {
+		case ok && annotation == "name":
+			err := finish()
+			if err != nil {
+				return nil, err
+			}
+
+			fields := strings.Fields(value)
+			if len(fields) != 2 || !inArray(fields[1], queryKinds) {
+				return fail("line %d: expected -- name: <Name> <%s>", i+1, strings.Join(queryKinds, "|"))
+			}
+			name := uppercaseFirst(fields[0])
+			if !token.IsIdentifier(name) {
+				return fail("line %d: %s is not a valid Go name", i+1, fields[0])
+			}
+			q = &query{Name: name, Kind: fields[1], ParamTypes: map[string]string{}, File: filepath.Base(file), Line: i + 1}
+
+		case ok && q != nil && len(lines) == 0 && annotation == "param":
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				return fail("line %d: expected -- param: <name> <type>", i+1)
+			}
+			q.ParamTypes[strings.TrimPrefix(fields[0], ":")] = strings.Join(fields[1:], " ")
+
+		case ok && q != nil && len(lines) == 0 && annotation == "result":
+			if q.Kind == ":exec" {
+				return fail("line %d: %s is an :exec query and has no result", i+1, q.Name)
+			}
+			for _, column := range splitColumns(value) {
+				fields := strings.Fields(column)
+				if len(fields) == 0 {
+					return fail("line %d: empty result column", i+1)
+				}
+				c := ResultColumn{Name: fields[0]}
+				if n := len(fields); n > 2 && strings.EqualFold(fields[n-1], "null") {
+					c.Null = true
+					fields = fields[:n-1]
+				}
+				c.Type = strings.Join(fields[1:], " ")
+				q.Result = append(q.Result, c)
+			}
+
+		case q != nil:
+			if len(lines) > 0 || strings.TrimSpace(line) != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	err := finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return queries, nil
+}
+
+// queryAnnotation splits a -- key: value comment into its key & value
+func queryAnnotation(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "--") {
+		return "", "", false
+	}
+
+	key, value, ok := strings.Cut(strings.TrimSpace(line[2:]), ":")
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !ok || (key != "name" && key != "param" && key != "result") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// splitColumns splits the columns of a -- result annotation on the commas outside of parentheses, so
+// decimal(10,2) stays a single type
+func splitColumns(s string) []string {
+	var columns []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				columns = append(columns, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(columns, s[start:])
+}
+
+// queryParams returns the names of the :name placeholders of a query in the order they first appear, and
+// whether it has ? placeholders. Placeholders inside string literals, quoted identifiers & comments are
+// skipped the way db.ExpandQuery skips them
+func queryParams(sql string) ([]string, bool) {
+	var params []string
+	positional := false
+	runes := []rune(sql)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote := c
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && quote != '`' {
+					i++
+				} else if runes[i] == quote {
+					break
+				}
+			}
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-', c == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && (runes[i] != '*' || runes[i+1] != '/'); i++ {
+			}
+			i++
+		case c == '?':
+			positional = true
+		case c == ':' && i+1 < len(runes) && isQueryNameStart(runes[i+1]) && (i == 0 || runes[i-1] != ':'):
+			end := i + 1
+			for end < len(runes) && (isQueryNameStart(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+			if name := string(runes[i+1 : end]); !inArray(name, params) {
+				params = append(params, name)
+			}
+			i = end - 1
+		}
+	}
+	return params, positional
+}
+
+func isQueryNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// queryParam returns the Go name of a parameter
+func queryParam(name string) string {
+	if token.IsKeyword(name) || inArray(name, queryLocals) {
+		return name + "Param"
+	}
+	return name
+}
+
+// queryLiteral returns the Go literal of a query, a raw string unless the query contains a backtick
+func queryLiteral(sql string) string {
+	if strings.Contains(sql, "`") {
+		return strconv.Quote(sql)
+	}
+	return "`" + sql + "`"
+}
+
+// buildQueries builds the {table}_queries.go file with a typed function for every named query of the .sql
+// files in the directory of the table package. The file is removed once there are no .sql files left
+func (g Gostruct) buildQueries(table string, schema tableSchema) error {
+	tableNaming := uppercaseFirst(table)
+	path := g.modelDir + "/" + tableNaming + "/" + tableNaming + "_queries.go"
+
+	files, err := g.queryFiles(table)
+	if err != nil {
+		return err
+	}
+
+	var queries []query
+	generated := g.generatedNames(table, schema)
+	names := map[string]string{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		parsed, err := parseQueries(file, string(data))
+		if err != nil {
+			return err
+		}
+		for _, q := range parsed {
+			declared := []string{q.Name, q.constName()}
+			if len(q.Result) > 0 {
+				declared = append(declared, q.rowType(), q.nilRowType())
+			}
+			for _, name := range declared {
+				if inArray(name, generated) {
+					return errors.New("query error: " + q.File + ": line " + strconv.Itoa(q.Line) + ": " + name + " clashes with a name of the generated code")
+				}
+				if other, ok := names[name]; ok {
+					return errors.New("query error: " + q.File + ": " + name + " is already defined in " + other)
+				}
+				names[name] = q.File
+			}
+		}
+		queries = append(queries, parsed...)
+	}
+
+	if len(queries) == 0 {
+		return g.removeFile(path)
+	}
+
+	columns := map[string]tableObj{}
+	for _, object := range schema.Columns {
+		columns[object.Name] = object
+	}
+
+	imports := map[string]string{
+		g.dbDir:                    "db",
+		"database/sql":             "",
+		"github.com/pkg/errors":    "",
+		"golang.org/x/net/context": "",
+	}
+
+	var constants, contents string
+	for _, q := range queries {
+		constant, body, err := g.buildQuery(table, q, columns, imports)
+		if err != nil {
+			return err
+		}
+		constants += constant
+		contents += body
+	}
+
+	contents = `package ` + tableNaming + importBlock(imports) + `
+
+// the queries of the .sql files in this directory
+const (` + constants + `
+)` + contents + "\n"
+	return g.writeGoFile(path, contents, true)
+}
+
+// buildQuery returns the constant of a named query along with its result structures & function. Parameters
+// are typed by their -- param annotation or the column with the same name
+func (g Gostruct) buildQuery(table string, q query, columns map[string]tableObj, imports map[string]string) (string, string, error) {
+	fail := func(message string) (string, string, error) {
+		return "", "", errors.New("query error: " + q.File + ": " + q.Name + " " + message)
+	}
+	addImports := func(t fieldType) {
+		for path, alias := range t.Imports {
+			imports[path] = alias
+		}
+	}
+
+	constName := q.constName()
+
+	params := []string{"ctx context.Context"}
+	var named []string
+	for _, name := range q.Params {
+		var t fieldType
+		if columnType, ok := q.ParamTypes[name]; ok {
+			list := strings.HasPrefix(columnType, "[]")
+			dataType, columnType := resultColumn(ResultColumn{Type: strings.TrimPrefix(columnType, "[]")})
+			t = g.paramType(table, name, dataType, columnType, false)
+			if list {
+				t.Type = "[]" + t.Type
+			}
+		} else if object, ok := columns[name]; ok {
+			object.IsNullable = "NO"
+			t, _ = g.columnType(table, object)
+		} else {
+			return fail("has no column or -- param annotation for :" + name)
+		}
+		addImports(t)
+
+		params = append(params, queryParam(name)+" "+t.Type)
+		named = append(named, `"`+name+`": `+queryParam(name))
+	}
+
+	args := ""
+	if len(named) > 0 {
+		args = ", map[string]interface{}{" + strings.Join(named, ", ") + "}"
+	}
+
+	var rowFields, rowNilFields, rowDest, rowValues []string
+	for _, c := range q.Result {
+		var t fieldType
+		if c.Type != "" {
+			dataType, columnType := resultColumn(c)
+			t = g.paramType(table, c.Name, dataType, columnType, c.Null)
+		} else if object, ok := columns[c.Name]; ok {
+			t, _ = g.columnType(table, object)
+		} else {
+			return fail("has no column or type for the result column " + c.Name)
+		}
+		addImports(t)
+
+		field := uppercaseFirst(c.Name)
+		rowFields = append(rowFields, "\n\t"+field+"\t"+t.Type+"\t`column:\""+c.Name+"\"`")
+		rowNilFields = append(rowNilFields, "\n\t"+field+"\t"+t.NilType)
+		rowDest = append(rowDest, "&obj."+field)
+		rowValues = append(rowValues, fmt.Sprintf(t.Convert, "obj."+field))
+	}
+
+	definition := "\n\t// " + constName + " is the " + q.Name + " query of " + q.File + "\n\t" + constName + " = " + queryLiteral(q.SQL)
+	doc := "\n\n// " + q.Name + " runs the " + q.Name + " query of " + q.File
+	signature := "\nfunc " + q.Name + "(" + strings.Join(params, ", ") + ") "
+	model := "*" + uppercaseFirst(table)
+
+	if len(q.Result) == 0 {
+		var body string
+		switch q.Kind {
+		case ":many":
+			body = "([]" + model + ", error) {\n\treturn defaultRepository.ReadByQuery(ctx, " + constName + args + ")\n}"
+		case ":one":
+			body = "(" + model + ", error) {\n\treturn defaultRepository.ReadOneByQuery(ctx, " + constName + args + ")\n}"
+		default:
+			body = "(sql.Result, error) {\n\treturn defaultRepository.Exec(ctx, " + constName + args + ")\n}"
+		}
+		return definition, doc + signature + body, nil
+	}
+
+	rowType, nilRowType := q.rowType(), q.nilRowType()
+	wrapErr := `errors.Wrap(db.ClassifyError(err), "query failed for ` + q.Name + `")`
+
+	types := `
+
+// ` + rowType + ` is a row of the result of the ` + q.Name + ` query
+type ` + rowType + ` struct {` + strings.Join(rowFields, "") + `
+}
+
+// ` + nilRowType + ` is the nilable structure the rows of the ` + q.Name + ` query are scanned into
+type ` + nilRowType + ` struct {` + strings.Join(rowNilFields, "") + `
+}`
+
+	returns := "([]*" + rowType + ", error)"
+	if q.Kind == ":one" {
+		returns = "(*" + rowType + ", error)"
+	}
+
+	body := returns + ` {
+	rows, err := defaultRepository.repo.Query(ctx, ` + constName + args + `)
+	if err != nil {
+		return nil, ` + wrapErr + `
+	}
+	defer rows.Close()
+`
+	if q.Kind == ":one" {
+		body += `
+	if !rows.Next() {
+		err = rows.Err()
+		if err != nil {
+			return nil, ` + wrapErr + `
+		}
+		return nil, db.ErrNotFound
+	}
+
+	var obj ` + nilRowType + `
+	err = rows.Scan(` + strings.Join(rowDest, ", ") + `)
+	if err != nil {
+		return nil, errors.Wrap(err, "scan failed for ` + q.Name + `")
+	}
+
+	return &` + rowType + `{` + strings.Join(rowValues, ", ") + `}, nil
+}`
+	} else {
+		body += `
+	var objects []*` + rowType + `
+	for rows.Next() {
+		var obj ` + nilRowType + `
+		err = rows.Scan(` + strings.Join(rowDest, ", ") + `)
+		if err != nil {
+			return nil, errors.Wrap(err, "scan failed for ` + q.Name + `")
+		}
+		objects = append(objects, &` + rowType + `{` + strings.Join(rowValues, ", ") + `})
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, ` + wrapErr + `
+	}
+
+	if len(objects) == 0 {
+		return objects, errors.Wrap(db.ErrNotFound, "no records found")
+	}
+
+	return objects, nil
+}`
+	}
+
+	return definition, types + doc + signature + body, nil
+}
@@ -0,0 +1,142 @@
+package gostruct
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQueries(t *testing.T) {
+	queries, err := parseQueries("orders.sql", `-- leading comments are ignored
+-- name: byAccount :many
+-- param: ids []int(11)
+-- result: id, total decimal(10,2) null
+SELECT id, total
+FROM orders
+WHERE account_id = :account_id AND id IN (:ids) AND note <> ':skipped' -- :commented
+AND account_id = :account_id;
+
+-- name: Archive :exec
+UPDATE orders SET archived = NOW() WHERE created < :before
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []query{
+		{
+			Name:       "ByAccount",
+			Kind:       ":many",
+			SQL:        "SELECT id, total\nFROM orders\nWHERE account_id = :account_id AND id IN (:ids) AND note <> ':skipped' -- :commented\nAND account_id = :account_id",
+			Params:     []string{"account_id", "ids"},
+			ParamTypes: map[string]string{"ids": "[]int(11)"},
+			Result:     []ResultColumn{{Name: "id"}, {Name: "total", Type: "decimal(10,2)", Null: true}},
+			File:       "orders.sql",
+			Line:       2,
+		},
+		{
+			Name:       "Archive",
+			Kind:       ":exec",
+			SQL:        "UPDATE orders SET archived = NOW() WHERE created < :before",
+			Params:     []string{"before"},
+			ParamTypes: map[string]string{},
+			File:       "orders.sql",
+			Line:       10,
+		},
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("got %+v, want %+v", queries, want)
+	}
+
+	for _, test := range []struct {
+		contents string
+		err      string
+	}{
+		{"-- name: Find :all\nSELECT 1", "expected -- name: <Name> <:many|:one|:exec>"},
+		{"-- name: Find :many\n", "Find has no statement"},
+		{"-- name: Find :many\nSELECT * FROM orders WHERE id = ?", "Find uses ? placeholders"},
+		{"-- name: Find :many\n-- param: id int(11)\nSELECT * FROM orders", "Find has no :id parameter"},
+		{"-- name: Archive :exec\n-- result: id\nDELETE FROM orders", "Archive is an :exec query and has no result"},
+	} {
+		_, err := parseQueries("orders.sql", test.contents)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: got %v, want %q", test.contents, err, test.err)
+		}
+	}
+
+	columns := map[string]tableObj{}
+	for _, object := range fixtures["post"].Columns {
+		columns[object.Name] = object
+	}
+	_, _, err = Gostruct{}.buildQuery("post", query{Name: "BySlug", Kind: ":one", Params: []string{"slug"}, File: "posts.sql"}, columns, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "has no column or -- param annotation for :slug") {
+		t.Errorf("unknown parameter: got %v", err)
+	}
+}
+
+// TestQueryNameClashes checks that queries can't take the names of the constants, functions & types of the
+// generated code
+func TestQueryNameClashes(t *testing.T) {
+	for _, test := range []struct {
+		table     string
+		name      string
+		nameFuncs bool
+		clashes   bool
+	}{
+		{"user", "Save", false, true},
+		{"user", "Select", false, true},
+		{"user", "ReadAll", false, true},
+		{"user", "ReadByKey", false, true},
+		{"user", "Exec", false, true},
+		{"user", "UserStatus", false, true},
+		{"user", "UserStatusActive", false, true},
+		{"user", "UserPermsInProgress", false, true},
+		{"user", "ReadAll", true, false},
+		{"user", "ReadAllUser", true, true},
+		{"user", "UserExec", true, true},
+		{"user_role", "Roles", false, true},
+		{"user_role", "NewLinks", false, true},
+		{"user_role", "User_roleKey", false, true},
+		{"user", "UserKey", false, false},
+		{"user", "ByEmail", false, false},
+		{"user", "Élevé", false, false},
+	} {
+		g := newGenerator(t.TempDir())
+		g.NameFuncs = test.nameFuncs
+		dir := g.modelDir + "/" + uppercaseFirst(test.table)
+		err := os.MkdirAll(dir, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(dir+"/queries.sql", []byte("-- the queries\n-- name: "+test.name+" :exec\nDELETE FROM "+test.table+"\n"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = g.buildQueries(test.table, fixtures[test.table])
+		want := "query error: queries.sql: line 2: " + test.name + " clashes with a name of the generated code"
+		if test.clashes && (err == nil || err.Error() != want) {
+			t.Errorf("%s: got %v, want %q", test.name, err, want)
+		}
+		if !test.clashes && err != nil {
+			t.Errorf("%s: got %v", test.name, err)
+		}
+	}
+
+	g := newGenerator(t.TempDir())
+	dir := g.modelDir + "/User"
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dir+"/queries.sql", []byte("-- name: Stats :one\n-- result: id\nSELECT id FROM user\n\n-- name: StatsRow :exec\nDELETE FROM user\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = g.buildQueries("user", fixtures["user"])
+	want := "query error: queries.sql: StatsRow is already defined in queries.sql"
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %q", err, want)
+	}
+}
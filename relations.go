@@ -0,0 +1,204 @@
+package gostruct
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// foreignKey is a single column of a table that references a column in another table
+type foreignKey struct {
+	Column    string `json:"column"`
+	RefTable  string `json:"referenced_table"`
+	RefColumn string `json:"referenced_column"`
+}
+
+// joinTable holds the two sides of a many-to-many relationship stored in a join table
+type joinTable struct {
+	Left  foreignKey
+	Right foreignKey
+}
+
+// getForeignKeys returns every foreign key column defined on a table
+func getForeignKeys(con *sql.DB, database, table string) ([]foreignKey, error) {
+	rows, err := con.Query("SELECT column_name, referenced_table_name, referenced_column_name FROM information_schema.key_column_usage WHERE table_schema = ? AND table_name = ? AND referenced_table_name IS NOT NULL ORDER BY ordinal_position", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		err = rows.Scan(&fk.Column, &fk.RefTable, &fk.RefColumn)
+		if err != nil {
+			return nil, err
+		}
+		fks = append(fks, fk)
+	}
+
+	return fks, rows.Err()
+}
+
+// detectJoinTable determines whether a table only links two other tables together. A join table has exactly
+// two foreign keys to two different tables, and every other column can be left out of an insert
+func detectJoinTable(objects []tableObj, fks []foreignKey) (joinTable, bool) {
+	if len(fks) != 2 || fks[0].RefTable == fks[1].RefTable || fks[0].Column == fks[1].Column {
+		return joinTable{}, false
+	}
+
+	for _, object := range objects {
+		if object.Name == fks[0].Column || object.Name == fks[1].Column {
+			continue
+		}
+		if object.IsNullable == "YES" || object.Default.Valid || strings.Contains(object.Extra.String, "auto_increment") {
+			continue
+		}
+		return joinTable{}, false
+	}
+
+	return joinTable{Left: fks[0], Right: fks[1]}, true
+}
+
+// buildJoin builds the {table}_join.go file with the many-to-many helpers for both sides of a join table.
+// The helpers live in the join table package because the two model packages can't import each other
+func (g Gostruct) buildJoin(table string, jt joinTable) error {
+	tableNaming := uppercaseFirst(table)
+	dir := g.modelDir + "/" + tableNaming + "/"
+
+	contents := `package ` + tableNaming + `
+
+import (
+	db "` + g.dbDir + `"
+	` + uppercaseFirst(jt.Left.RefTable) + ` "` + g.modelImport(jt.Left.RefTable) + `"
+	` + uppercaseFirst(jt.Right.RefTable) + ` "` + g.modelImport(jt.Right.RefTable) + `"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Links holds the many-to-many helpers of the ` + table + ` table. The package functions run them through the
+// shared connection to the ` + g.Database + ` database
+type Links struct {
+	repo db.Repo[*` + tableNaming + `]
+}
+
+// defaultLinks runs the package functions through the default repository
+var defaultLinks = &Links{repo: defaultRepository.repo}
+
+// NewLinks returns the many-to-many helpers that run every query through ex, such as a *sql.Tx
+func NewLinks(ex db.Executor) *Links {
+	r := defaultRepository.repo
+	r.Executor = ex
+	return &Links{repo: r}
+}
+`
+	contents += g.joinSide(table, jt.Left, jt.Right)
+	contents += g.joinSide(table, jt.Right, jt.Left)
+
+	joinFile := dir + tableNaming + "_join.go"
+	return g.writeGoFile(joinFile, contents, true)
+}
+
+// joinSide returns the read, add, remove & set helpers that link an owner record to its related records
+func (g Gostruct) joinSide(table string, owner, related foreignKey) string {
+	ownerType := uppercaseFirst(owner.RefTable) + "." + uppercaseFirst(owner.RefTable)
+	relatedPkg := uppercaseFirst(related.RefTable)
+	relatedType := relatedPkg + "." + relatedPkg
+	single := relatedPkg
+	plural := pluralize(relatedPkg)
+	ownerKey := "obj." + uppercaseFirst(owner.RefColumn)
+	relatedKey := "." + uppercaseFirst(related.RefColumn)
+	insertQuery := "INSERT IGNORE INTO `" + table + "` (`" + owner.Column + "`, `" + related.Column + "`) VALUES (?, ?)"
+	deleteQuery := "DELETE FROM `" + table + "` WHERE `" + owner.Column + "` = ?"
+
+	// the related records are read through the select of their package, which leaves out soft deleted records
+	readQuery := `"SELECT * FROM ("+` + relatedPkg + `.SelectQuery+") AS ` + "`" + related.RefTable + "`" + ` WHERE ` + "`" + related.RefColumn + "`" +
+		` IN (SELECT ` + "`" + related.Column + "`" + ` FROM ` + "`" + table + "`" + ` WHERE ` + "`" + owner.Column + "`" + ` = ?)"`
+
+	return `
+// ` + plural + ` returns every ` + related.RefTable + ` linked to the ` + owner.RefTable + ` through the ` + table + ` table
+func ` + plural + `(ctx context.Context, obj *` + ownerType + `) ([]*` + relatedType + `, error) {
+	return defaultLinks.` + plural + `(ctx, obj)
+}
+
+// ` + plural + ` returns every ` + related.RefTable + ` linked to the ` + owner.RefTable + ` through the ` + table + ` table
+func (l *Links) ` + plural + `(ctx context.Context, obj *` + ownerType + `) ([]*` + relatedType + `, error) {
+	objects, err := ` + relatedPkg + `.NewRepository(l.repo.Executor).ReadByQuery(ctx, ` + readQuery + `, ` + ownerKey + `)
+	if errors.Cause(err) == db.ErrNotFound {
+		return objects, nil
+	}
+
+	return objects, err
+}
+
+// Add` + single + ` links a ` + related.RefTable + ` to the ` + owner.RefTable + `. Linking an already linked pair is a no-op
+func Add` + single + `(ctx context.Context, obj *` + ownerType + `, related *` + relatedType + `) error {
+	return defaultLinks.Add` + single + `(ctx, obj, related)
+}
+
+// Add` + single + ` links a ` + related.RefTable + ` to the ` + owner.RefTable + `. Linking an already linked pair is a no-op
+func (l *Links) Add` + single + `(ctx context.Context, obj *` + ownerType + `, related *` + relatedType + `) error {
+	_, err := l.repo.Exec(ctx, "` + insertQuery + `", ` + ownerKey + `, related` + relatedKey + `)
+	return errors.Wrap(err, "insert failed for ` + table + `")
+}
+
+// Remove` + single + ` unlinks a ` + related.RefTable + ` from the ` + owner.RefTable + `
+func Remove` + single + `(ctx context.Context, obj *` + ownerType + `, related *` + relatedType + `) error {
+	return defaultLinks.Remove` + single + `(ctx, obj, related)
+}
+
+// Remove` + single + ` unlinks a ` + related.RefTable + ` from the ` + owner.RefTable + `
+func (l *Links) Remove` + single + `(ctx context.Context, obj *` + ownerType + `, related *` + relatedType + `) error {
+	_, err := l.repo.Exec(ctx, "` + deleteQuery + ` AND ` + "`" + related.Column + "`" + ` = ?", ` + ownerKey + `, related` + relatedKey + `)
+	return errors.Wrap(err, "delete failed for ` + table + `")
+}
+
+// Set` + plural + ` replaces every ` + related.RefTable + ` linked to the ` + owner.RefTable + ` with the given list in a single transaction
+func Set` + plural + `(ctx context.Context, obj *` + ownerType + `, related []*` + relatedType + `) error {
+	return defaultLinks.Set` + plural + `(ctx, obj, related)
+}
+
+// Set` + plural + ` replaces every ` + related.RefTable + ` linked to the ` + owner.RefTable + ` with the given list. It runs in a
+// transaction of its own unless the helpers run through an executor, which then controls the transaction
+func (l *Links) Set` + plural + `(ctx context.Context, obj *` + ownerType + `, related []*` + relatedType + `) error {
+	if l.repo.Executor != nil {
+		return l.set` + plural + `(ctx, obj, related)
+	}
+
+	con, err := db.Get("` + g.Database + `")
+	if err != nil {
+		return errors.Wrap(err, "connection failed")
+	}
+
+	tx, err := con.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin failed")
+	}
+
+	err = NewLinks(tx).set` + plural + `(ctx, obj, related)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// set` + plural + ` replaces the linked ` + related.RefTable + ` records through the executor of the helpers
+func (l *Links) set` + plural + `(ctx context.Context, obj *` + ownerType + `, related []*` + relatedType + `) error {
+	_, err := l.repo.Exec(ctx, "` + deleteQuery + `", ` + ownerKey + `)
+	if err != nil {
+		return errors.Wrap(err, "delete failed for ` + table + `")
+	}
+
+	for _, r := range related {
+		_, err = l.repo.Exec(ctx, "` + insertQuery + `", ` + ownerKey + `, r` + relatedKey + `)
+		if err != nil {
+			return errors.Wrap(err, "insert failed for ` + table + `")
+		}
+	}
+
+	return nil
+}
+`
+}
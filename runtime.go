@@ -0,0 +1,1355 @@
+package gostruct
+
+// connectionFiles are the files of the connection package that are rewritten on every run. connection.go
+// is only created once so that its connection settings can be customized
+var connectionFiles = map[string]string{
+	"errors.go":     errorsFile,
+	"fake.go":       fakeFile,
+	"info.go":       infoFile,
+	"null.go":       nullFile,
+	"null_test.go":  nullTestFile + nullTestTypes,
+	"query.go":      queryFile,
+	"query_test.go": queryTestFile,
+	"repo.go":       repoFile,
+	"types.go":      typesFile,
+}
+
+const infoFile = `package connection
+
+// Info is implemented by every generated model to allow for retrieving the type & typeId of any object
+type Info interface {
+	TypeInfo() (string, interface{})
+}
+`
+
+const typesFile = `package connection
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration holds the value of a TIME column, which can be negative and exceed 24 hours
+type Duration struct {
+	time.Duration
+}
+
+// Scan implements the sql.Scanner interface for values formatted as [-]HHH:MM:SS[.ffffff]
+func (d *Duration) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case nil:
+		d.Duration = 0
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Duration", src)
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	parts := strings.Split(strings.TrimPrefix(s, "-"), ":")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid time value: %s", s)
+	}
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time value: %s", s)
+	}
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid time value: %s", s)
+	}
+	seconds, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return fmt.Errorf("invalid time value: %s", s)
+	}
+
+	d.Duration = time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)+0.5)
+	if negative {
+		d.Duration = -d.Duration
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (d Duration) Value() (driver.Value, error) {
+	v, sign := d.Duration, ""
+	if v < 0 {
+		v, sign = -v, "-"
+	}
+	hours := v / time.Hour
+	v -= hours * time.Hour
+	minutes := v / time.Minute
+	v -= minutes * time.Minute
+	seconds := v / time.Second
+	v -= seconds * time.Second
+
+	return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hours, minutes, seconds, v/time.Microsecond), nil
+}
+
+// Bit holds the value of a BIT column of up to 64 bits
+type Bit uint64
+
+// Scan implements the sql.Scanner interface for the big-endian bytes returned by the driver
+func (b *Bit) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		*b = 0
+		for _, c := range v {
+			*b = *b<<8 | Bit(c)
+		}
+	case int64:
+		*b = Bit(v)
+	case nil:
+		*b = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Bit", src)
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (b Bit) Value() (driver.Value, error) {
+	if b>>63 == 0 {
+		return int64(b), nil
+	}
+
+	bts := make([]byte, 8)
+	for i := range bts {
+		bts[i] = byte(b >> uint(56-8*i))
+	}
+	return bts, nil
+}
+`
+
+const nullFile = `package connection
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Int64Ptr returns a pointer to the value of n, or nil when n is NULL
+func Int64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Int64
+	return &v
+}
+
+// Float64Ptr returns a pointer to the value of n, or nil when n is NULL
+func Float64Ptr(n sql.NullFloat64) *float64 {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Float64
+	return &v
+}
+
+// BoolPtr returns a pointer to the value of n, or nil when n is NULL
+func BoolPtr(n sql.NullBool) *bool {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Bool
+	return &v
+}
+
+// StringPtr returns a pointer to the value of n, or nil when n is NULL
+func StringPtr(n sql.NullString) *string {
+	if !n.Valid {
+		return nil
+	}
+	v := n.String
+	return &v
+}
+
+// TimePtr returns a pointer to the value of n, or nil when n is NULL
+func TimePtr(n mysql.NullTime) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	v := n.Time
+	return &v
+}
+`
+
+const nullTestFile = `package connection
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/shopspring/decimal"
+)
+
+// echoDriver returns the arguments of every query as a single row, the way MySQL returns them over the
+// binary protocol, so values can be round-tripped through database/sql like the generated models do
+type echoDriver struct{}
+
+type echoConn struct{}
+
+type echoStmt struct{}
+
+type echoRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (echoDriver) Open(name string) (driver.Conn, error)         { return echoConn{}, nil }
+func (echoConn) Prepare(query string) (driver.Stmt, error)       { return echoStmt{}, nil }
+func (echoConn) Close() error                                    { return nil }
+func (echoConn) Begin() (driver.Tx, error)                       { return nil, driver.ErrSkip }
+func (echoStmt) Close() error                                    { return nil }
+func (echoStmt) NumInput() int                                   { return -1 }
+func (echoStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (echoStmt) Query(args []driver.Value) (driver.Rows, error)  { return &echoRows{values: args}, nil }
+func (r *echoRows) Columns() []string                            { return make([]string, len(r.values)) }
+func (r *echoRows) Close() error                                 { return nil }
+
+func (r *echoRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	for i, v := range r.values {
+		switch t := v.(type) {
+		case string:
+			dest[i] = []byte(t)
+		case bool:
+			if t {
+				dest[i] = int64(1)
+			} else {
+				dest[i] = int64(0)
+			}
+		default:
+			dest[i] = v
+		}
+	}
+	return nil
+}
+
+func init() {
+	sql.Register("gostruct_echo", echoDriver{})
+}
+
+// TestNullRoundTrip makes sure every nullable type the generator emits turns NULL into nil and keeps
+// every other value intact
+func TestNullRoundTrip(t *testing.T) {
+	con, err := sql.Open("gostruct_echo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	tests := []struct {
+		name  string
+		value interface{}
+		scan  func(row *sql.Row) (interface{}, error)
+	}{
+		{"int64", int64(-42), func(row *sql.Row) (interface{}, error) {
+			var n sql.NullInt64
+			err := row.Scan(&n)
+			return Int64Ptr(n), err
+		}},
+		{"float64", 1.5, func(row *sql.Row) (interface{}, error) {
+			var n sql.NullFloat64
+			err := row.Scan(&n)
+			return Float64Ptr(n), err
+		}},
+		{"bool", true, func(row *sql.Row) (interface{}, error) {
+			var n sql.NullBool
+			err := row.Scan(&n)
+			return BoolPtr(n), err
+		}},
+		{"string", "gostruct", func(row *sql.Row) (interface{}, error) {
+			var n sql.NullString
+			err := row.Scan(&n)
+			return StringPtr(n), err
+		}},
+		{"time", time.Date(2017, 6, 1, 12, 30, 0, 0, time.UTC), func(row *sql.Row) (interface{}, error) {
+			var n mysql.NullTime
+			err := row.Scan(&n)
+			return TimePtr(n), err
+		}},
+		{"uint8", uint8(255), func(row *sql.Row) (interface{}, error) {
+			var n *uint8
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"uint16", uint16(65535), func(row *sql.Row) (interface{}, error) {
+			var n *uint16
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"uint32", uint32(4294967295), func(row *sql.Row) (interface{}, error) {
+			var n *uint32
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"uint64", uint64(1) << 40, func(row *sql.Row) (interface{}, error) {
+			var n *uint64
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"year", int16(2017), func(row *sql.Row) (interface{}, error) {
+			var n *int16
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"duration", Duration{-(838*time.Hour + 59*time.Minute + 59*time.Second + time.Microsecond)}, func(row *sql.Row) (interface{}, error) {
+			var n *Duration
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"bit", Bit(1<<63 | 5), func(row *sql.Row) (interface{}, error) {
+			var n *Bit
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"binary", []byte{0, 1, 2}, func(row *sql.Row) (interface{}, error) {
+			var n []byte
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"json", json.RawMessage(` + "`" + `{"id":1}` + "`" + `), func(row *sql.Row) (interface{}, error) {
+			var n []byte
+			err := row.Scan(&n)
+			if n == nil {
+				return json.RawMessage(nil), err
+			}
+			return json.RawMessage(n), err
+		}},
+		{"decimal", decimal.New(-1250, -2), func(row *sql.Row) (interface{}, error) {
+			var n *decimal.Decimal
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"enum", SampleKindB, func(row *sql.Row) (interface{}, error) {
+			var n *SampleKind
+			err := row.Scan(&n)
+			return n, err
+		}},
+		{"set", SamplePermsRead | SamplePermsWrite, func(row *sql.Row) (interface{}, error) {
+			var n *SamplePerms
+			err := row.Scan(&n)
+			return n, err
+		}},
+	}
+
+	for _, test := range tests {
+		for _, value := range []interface{}{test.value, nil} {
+			got, err := test.scan(con.QueryRow("SELECT ?", value))
+			if err != nil {
+				t.Errorf("%s: scan failed for %v: %v", test.name, value, err)
+				continue
+			}
+
+			v := reflect.ValueOf(got)
+			if value == nil {
+				if !v.IsNil() {
+					t.Errorf("%s: expected nil for NULL, got %v", test.name, v.Elem())
+				}
+				continue
+			}
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					t.Errorf("%s: expected %v, got nil", test.name, value)
+					continue
+				}
+				v = v.Elem()
+			}
+			// decimals are compared by value, since their representation may change on the way
+			if v.Type() != reflect.TypeOf(value) || !Equal(v.Interface(), value) {
+				t.Errorf("%s: expected %v, got %v", test.name, value, v.Interface())
+			}
+		}
+	}
+}
+`
+
+// nullTestTypes are an enum & a set type the way they are generated for a model, so the round trip covers
+// their nullable pointers as well
+var nullTestTypes = buildEnumType("sample", tableObj{Name: "kind", DataType: "enum", ColumnType: "enum('a','b')"}) +
+	buildSetType("sample", tableObj{Name: "perms", DataType: "set", ColumnType: "set('read','write')"}) + "\n"
+
+const errorsFile = `package connection
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	// ErrNotFound is returned when no record matches a read. errors.Is also matches it against sql.ErrNoRows
+	ErrNotFound error = notFoundError{}
+	// ErrDuplicateKey is returned when a write violates a primary or unique key (MySQL error 1062)
+	ErrDuplicateKey = errors.New("duplicate key")
+	// ErrForeignKeyViolation is returned when a write violates a foreign key (MySQL errors 1451 & 1452)
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	// ErrValidation is matched by every *ValidationError
+	ErrValidation = errors.New("validation failed")
+	// ErrStale is returned when a record was changed by someone else since it was read
+	ErrStale = errors.New("stale record")
+)
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "record not found"
+}
+
+// Is allows for callers that still check for sql.ErrNoRows
+func (notFoundError) Is(target error) bool {
+	return target == sql.ErrNoRows
+}
+
+// FieldError describes why the value of a single column is invalid
+type FieldError struct {
+	Column  string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Column + ": " + e.Message
+}
+
+// ValidationError holds every invalid column of a record
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return ErrValidation.Error() + ": " + strings.Join(messages, "; ")
+}
+
+// Is makes every *ValidationError match ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// classifiedError is a MySQL error matched to one of the sentinel errors
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+// ClassifyError matches errors returned by MySQL to the sentinel errors, keeping the original error available
+// through errors.As. Any other error is returned as is
+func ClassifyError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if err == nil || !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case 1062:
+		return &classifiedError{kind: ErrDuplicateKey, err: err}
+	case 1451, 1452:
+		return &classifiedError{kind: ErrForeignKeyViolation, err: err}
+	}
+
+	return err
+}
+`
+
+const queryFile = `package connection
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ExpandQuery prepares a query so that every value stays a bound parameter:
+//
+// - a slice argument for a ? placeholder is expanded into one placeholder per element, so IN (?) becomes
+// IN (?, ?, ?). An empty slice becomes NULL, which matches nothing
+//
+// - when the only argument is a map with string keys or a struct, :name placeholders are replaced by ? and bound
+// to the map value or the struct field with the matching column tag or name
+//
+// Placeholders inside string literals, quoted identifiers and comments are left alone.
+func ExpandQuery(query string, args ...interface{}) (string, []interface{}, error) {
+	var named reflect.Value
+	if len(args) == 1 {
+		named = namedSource(args[0])
+	}
+
+	var out strings.Builder
+	var newArgs []interface{}
+	positional, usedNamed := 0, false
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\'' || c == '"' || c == '` + "`" + `':
+			end := skipQuoted(runes, i)
+			out.WriteString(string(runes[i:end]))
+			i = end - 1
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-', c == '#':
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			out.WriteString(string(runes[i:end]))
+			i = end - 1
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := i + 2
+			for end+1 < len(runes) && (runes[end] != '*' || runes[end+1] != '/') {
+				end++
+			}
+			end += 2
+			if end > len(runes) {
+				end = len(runes)
+			}
+			out.WriteString(string(runes[i:end]))
+			i = end - 1
+		case c == '?':
+			if positional >= len(args) {
+				return "", nil, fmt.Errorf("not enough arguments for query: %s", query)
+			}
+			newArgs = appendArg(&out, newArgs, args[positional])
+			positional++
+		case c == ':' && named.IsValid() && i+1 < len(runes) && isNameStart(runes[i+1]) && (i == 0 || runes[i-1] != ':'):
+			end := i + 1
+			for end < len(runes) && (isNameStart(runes[end]) || unicode.IsDigit(runes[end])) {
+				end++
+			}
+			name := string(runes[i+1 : end])
+			value, ok := namedValue(named, name)
+			if !ok {
+				return "", nil, fmt.Errorf("no value for named parameter :%s", name)
+			}
+			newArgs = appendArg(&out, newArgs, value)
+			usedNamed = true
+			i = end - 1
+		default:
+			out.WriteRune(c)
+		}
+	}
+
+	if usedNamed && positional > 0 {
+		return "", nil, fmt.Errorf("query mixes ? and named parameters: %s", query)
+	}
+	if !usedNamed && positional != len(args) {
+		return "", nil, fmt.Errorf("expected %d arguments, got %d for query: %s", positional, len(args), query)
+	}
+
+	return out.String(), newArgs, nil
+}
+
+// skipQuoted returns the index right after the quoted string, identifier or literal that starts at i
+func skipQuoted(runes []rune, i int) int {
+	quote := runes[i]
+	for j := i + 1; j < len(runes); j++ {
+		switch {
+		case runes[j] == '\\' && quote != '` + "`" + `':
+			j++
+		case runes[j] == quote && j+1 < len(runes) && runes[j+1] == quote:
+			j++
+		case runes[j] == quote:
+			return j + 1
+		}
+	}
+	return len(runes)
+}
+
+// appendArg writes the placeholder(s) for a value and returns the arguments they are bound to
+func appendArg(out *strings.Builder, args []interface{}, value interface{}) []interface{} {
+	if !isList(value) {
+		out.WriteString("?")
+		return append(args, value)
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Len() == 0 {
+		out.WriteString("NULL")
+		return args
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString("?")
+		args = append(args, v.Index(i).Interface())
+	}
+	return args
+}
+
+// isList determines whether a value should be expanded into a list of placeholders. Byte slices and types
+// that convert themselves into a single value are not
+func isList(value interface{}) bool {
+	if _, ok := value.(driver.Valuer); ok || value == nil {
+		return false
+	}
+	t := reflect.TypeOf(value)
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+// namedSource returns the map or struct that named parameters are read from, if the argument is one. Times and
+// types that convert themselves into a single value are bound as is, also when they are passed by pointer
+func namedSource(arg interface{}) reflect.Value {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || isValue(v.Type()) {
+		return reflect.Value{}
+	}
+
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		return v
+	case v.Kind() == reflect.Struct:
+		return v
+	}
+	return reflect.Value{}
+}
+
+// isValue determines whether a type, or a pointer to it, is bound as a single value
+func isValue(t reflect.Type) bool {
+	valuer := reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	return t == reflect.TypeOf(time.Time{}) || t.Implements(valuer) || reflect.PtrTo(t).Implements(valuer)
+}
+
+// namedValue returns the value of a named parameter from a map or struct
+func namedValue(source reflect.Value, name string) (interface{}, bool) {
+	if source.Kind() == reflect.Map {
+		v := source.MapIndex(reflect.ValueOf(name).Convert(source.Type().Key()))
+		if !v.IsValid() {
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+
+	t := source.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Tag.Get("column") == name || strings.EqualFold(field.Name, name) {
+			return source.Field(i).Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+`
+
+const queryTestFile = `package connection
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cents is a struct that converts itself into a single value
+type cents struct {
+	Amount int64
+}
+
+func (c cents) Value() (driver.Value, error) {
+	return c.Amount, nil
+}
+
+func TestExpandQuery(t *testing.T) {
+	type person struct {
+		ID    int64  ` + "`" + `column:"id"` + "`" + `
+		Email string ` + "`" + `column:"email"` + "`" + `
+	}
+
+	tests := []struct {
+		query string
+		args  []interface{}
+		want  string
+		vals  []interface{}
+	}{
+		{"SELECT * FROM user WHERE id = ?", []interface{}{1}, "SELECT * FROM user WHERE id = ?", []interface{}{1}},
+		{"SELECT * FROM user WHERE id IN (?) AND name = ?", []interface{}{[]int{1, 2, 3}, "x"}, "SELECT * FROM user WHERE id IN (?, ?, ?) AND name = ?", []interface{}{1, 2, 3, "x"}},
+		{"SELECT * FROM user WHERE id IN (?)", []interface{}{[]string{}}, "SELECT * FROM user WHERE id IN (NULL)", nil},
+		{"SELECT * FROM user WHERE data = ?", []interface{}{[]byte("ab")}, "SELECT * FROM user WHERE data = ?", []interface{}{[]byte("ab")}},
+		{"SELECT * FROM user WHERE name = 'it''s ?' AND id = ?", []interface{}{1}, "SELECT * FROM user WHERE name = 'it''s ?' AND id = ?", []interface{}{1}},
+		{"SELECT * FROM user WHERE name = \"a\\\" ?\" AND id = ?", []interface{}{1}, "SELECT * FROM user WHERE name = \"a\\\" ?\" AND id = ?", []interface{}{1}},
+		{"SELECT * FROM user -- why?\nWHERE id = ?", []interface{}{1}, "SELECT * FROM user -- why?\nWHERE id = ?", []interface{}{1}},
+		{"SELECT * FROM user /* ? :id */ WHERE id = ?", []interface{}{1}, "SELECT * FROM user /* ? :id */ WHERE id = ?", []interface{}{1}},
+		{"SELECT * FROM user WHERE email = :email AND id IN (:ids)", []interface{}{map[string]interface{}{"email": "a@b.c", "ids": []int64{4, 5}}}, "SELECT * FROM user WHERE email = ? AND id IN (?, ?)", []interface{}{"a@b.c", int64(4), int64(5)}},
+		{"SELECT * FROM user WHERE email = :email AND id = :id AND created > '12:00:00'", []interface{}{&person{ID: 7, Email: "a@b.c"}}, "SELECT * FROM user WHERE email = ? AND id = ? AND created > '12:00:00'", []interface{}{"a@b.c", int64(7)}},
+	}
+
+	for _, test := range tests {
+		got, vals, err := ExpandQuery(test.query, test.args...)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got != test.want || !reflect.DeepEqual(vals, test.vals) {
+			t.Errorf("%s: expected %s %v, got %s %v", test.query, test.want, test.vals, got, vals)
+		}
+	}
+}
+
+// TestNamedSource checks that times & driver.Valuer structs are bound as is instead of being read as the
+// source of named parameters, also when they are passed by pointer
+func TestNamedSource(t *testing.T) {
+	now := time.Now()
+	for _, arg := range []interface{}{now, &now, cents{5}, &cents{5}, (*time.Time)(nil), nil} {
+		if namedSource(arg).IsValid() {
+			t.Errorf("%#v: expected no named source", arg)
+		}
+	}
+
+	query, vals, err := ExpandQuery("SELECT * FROM user WHERE amount > ?", &cents{5})
+	if err != nil || query != "SELECT * FROM user WHERE amount > ?" || !reflect.DeepEqual(vals, []interface{}{&cents{5}}) {
+		t.Errorf("expected the argument to be bound as is, got %s %v %v", query, vals, err)
+	}
+}
+
+func TestExpandQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"SELECT * FROM user WHERE id = ? AND name = ?", []interface{}{1}},
+		{"SELECT * FROM user WHERE id = ?", []interface{}{1, 2}},
+		{"SELECT * FROM user WHERE email = :email", []interface{}{map[string]interface{}{"id": 1}}},
+	}
+
+	for _, test := range tests {
+		if _, _, err := ExpandQuery(test.query, test.args...); err == nil {
+			t.Errorf("%s: expected an error", test.query)
+		}
+	}
+}
+
+func TestOmitColumns(t *testing.T) {
+	columns := []string{"id", "name", "created", "version"}
+	updates := []string{"", "name = VALUES(name)", "created = VALUES(created)", "", "version = version + 1"}
+	query, args := OmitColumns("user", columns, updates, []interface{}{nil, "x", "", int64(3)}, []int{2})
+
+	want := "INSERT INTO ` + "`" + `user` + "`" + ` (id, name, version) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name), version = version + 1"
+	if query != want || !reflect.DeepEqual(args, []interface{}{nil, "x", int64(3)}) {
+		t.Errorf("expected %s [<nil> x 3], got %s %v", want, query, args)
+	}
+}
+`
+
+const repoFile = `package connection
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// Executor runs queries. It is satisfied by *sql.DB, *sql.Tx & *sql.Conn
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Model is implemented by every generated model
+type Model interface {
+	TableName() string
+	Validate() error
+}
+
+// Scanner returns the scan destinations for the columns of a result set, matched by name, and a function that
+// converts the scanned values into a model
+type Scanner[T Model] func(columns []string) ([]interface{}, func() T)
+
+// Repo holds the metadata of a generated model that the shared read & write helpers need
+type Repo[T Model] struct {
+	// Database is the name of the database the table lives in
+	Database string
+	// Table is the name of the table
+	Table string
+	// Scan matches the columns of a result set to a new model
+	Scan Scanner[T]
+	// Executor runs the queries. The shared connection to the database is used when it is nil
+	Executor Executor
+}
+
+// executor returns the executor of the repo, falling back to the shared connection to the database
+func (r Repo[T]) executor() (Executor, error) {
+	if r.Executor != nil {
+		return r.Executor, nil
+	}
+
+	con, err := Get(r.Database)
+	if err != nil {
+		return nil, errors.Wrap(err, "connection failed")
+	}
+
+	return con, nil
+}
+
+// Find returns every record of a query. A wrapped ErrNotFound is returned along with the empty list when there
+// are none
+func Find[T Model](ctx context.Context, r Repo[T], query string, args ...interface{}) ([]T, error) {
+	var objects []T
+	err := Stream(ctx, r, query, func(obj T) error {
+		objects = append(objects, obj)
+		return nil
+	}, args...)
+	if err != nil {
+		return objects, err
+	}
+
+	if len(objects) == 0 {
+		err = errors.Wrap(ErrNotFound, "no records found")
+	}
+
+	return objects, err
+}
+
+// FindOne returns the first record of a query, or ErrNotFound when there is none
+func FindOne[T Model](ctx context.Context, r Repo[T], query string, args ...interface{}) (T, error) {
+	var obj T
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return obj, errors.Wrap(err, "query/scan error")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return obj, errors.Wrap(err, "query/scan error")
+	}
+
+	if !rows.Next() {
+		err = rows.Err()
+		if err != nil {
+			return obj, errors.Wrap(err, "query/scan error")
+		}
+		return obj, ErrNotFound
+	}
+
+	dest, model := r.Scan(columns)
+	err = rows.Scan(dest...)
+	if err != nil {
+		return obj, errors.Wrap(err, "query/scan error")
+	}
+
+	return model(), nil
+}
+
+// Stream calls fn for every record of a query as it is read, without holding the whole result set in memory.
+// Streaming stops at the first error returned by fn
+func Stream[T Model](ctx context.Context, r Repo[T], query string, fn func(T) error, args ...interface{}) error {
+	args = ApplyQueryOptions(&query, args)
+	rows, err := r.Query(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.Wrap(err, "columns error")
+	}
+
+	for rows.Next() {
+		dest, model := r.Scan(columns)
+		err = rows.Scan(dest...)
+		if err != nil {
+			return errors.Wrap(err, "scan error")
+		}
+		err = fn(model())
+		if err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "rows error")
+}
+
+// Query expands the arguments of a query & runs it, for the queries whose rows aren't scanned into the model
+func (r Repo[T]) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	con, err := r.executor()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err = ExpandQuery(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return con.QueryContext(ctx, query, args...)
+}
+
+// Exec allows for update queries. MySQL errors are matched to the sentinel errors
+func (r Repo[T]) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	con, err := r.executor()
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err = ExpandQuery(query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	res, err := con.ExecContext(ctx, query, args...)
+	return res, ClassifyError(err)
+}
+
+// Save validates a model and runs its INSERT..UPDATE ON DUPLICATE KEY query, whose update list assigns the
+// inserted values
+func (r Repo[T]) Save(ctx context.Context, obj T, query string, args []interface{}) (sql.Result, error) {
+	err := obj.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "field validation error")
+	}
+
+	con, err := r.executor()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := con.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, errors.Wrap(ClassifyError(err), "save failed for "+r.Table)
+	}
+
+	return res, nil
+}
+
+// SaveVersioned validates a model and runs its versioned INSERT..UPDATE ON DUPLICATE KEY query, which leaves the
+// stored record untouched when its version changed. ErrStale is returned then
+func (r Repo[T]) SaveVersioned(ctx context.Context, obj T, query string, args []interface{}) (sql.Result, error) {
+	err := obj.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "field validation error")
+	}
+
+	con, err := r.executor()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := con.ExecContext(ctx, query, args...)
+	if err != nil {
+		return res, errors.Wrap(ClassifyError(err), "save failed for "+r.Table)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return res, errors.Wrap(err, "save failed for "+r.Table)
+	}
+	if rows == 0 {
+		return res, errors.Wrap(ErrStale, "save failed for "+r.Table)
+	}
+
+	return res, nil
+}
+
+// OmitColumns returns the INSERT..UPDATE ON DUPLICATE KEY query of a table & its arguments without the columns at
+// the omitted indexes, so a new record takes their defaults & an existing one keeps its values. updates holds
+// the assignment of every column, or an empty string for a key column or a column that is assigned last,
+// followed by those assignments
+func OmitColumns(table string, columns, updates []string, args []interface{}, omit []int) (string, []interface{}) {
+	var names, marks, assignments []string
+	var kept []interface{}
+	for i, column := range columns {
+		if len(omit) > 0 && omit[0] == i {
+			omit = omit[1:]
+			continue
+		}
+		names = append(names, column)
+		marks = append(marks, "?")
+		kept = append(kept, args[i])
+		if updates[i] != "" {
+			assignments = append(assignments, updates[i])
+		}
+	}
+	assignments = append(assignments, updates[len(columns):]...)
+	if len(assignments) == 0 {
+		assignments = append(assignments, names[0]+" = "+names[0])
+	}
+
+	return "INSERT INTO ` + "`" + `" + table + "` + "`" + ` (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(marks, ", ") + ") ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", "), kept
+}
+`
+
+const fakeFile = `package connection
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupported is returned by the generated fakes for custom queries they have no answer for
+var ErrUnsupported = errors.New("query not supported by the fake")
+
+// FakeTable is an in-memory table behind the generated fakes. Records are copied in & out, so changing a record
+// after saving or reading it doesn't change the stored one
+type FakeTable[T Model] struct {
+	// Name is the name of the table
+	Name string
+	// Key returns the primary key of a record. Records are only appended when it is nil
+	Key func(T) []interface{}
+	// Unique returns the values of every unique index of a record by index name, or nil when a column is NULL
+	Unique map[string]func(T) []interface{}
+	// Columns returns the value of every column of a record by lowercase column name
+	Columns map[string]func(T) interface{}
+	// Copy returns a deep copy of a record, which shares no memory with it
+	Copy func(T) T
+	// AutoIncrement assigns next to the auto_increment column of a record when it is empty, and returns the
+	// value of the column
+	AutoIncrement func(obj T, next int64) int64
+	// Deleted reports whether a record is soft deleted. Soft deleted records are left out of Get & All
+	Deleted func(T) bool
+	// SoftDelete marks a record as deleted. Delete removes records when it is nil
+	SoftDelete func(T)
+	// Version returns the version of a record, after incrementing it when increment is set. Save returns
+	// ErrStale when the version of a record differs from the stored one
+	Version func(obj T, increment bool) int64
+
+	mu      sync.Mutex
+	records []T
+	lastID  int64
+}
+
+// fakeResult is the sql.Result of a fake write
+type fakeResult struct {
+	lastID int64
+	rows   int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastID, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.rows, nil
+}
+
+// Get returns the record with the given primary key, or ErrNotFound
+func (t *FakeTable[T]) Get(key ...interface{}) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var obj T
+	i := t.find(key)
+	if i < 0 || t.deleted(t.records[i]) {
+		return obj, ErrNotFound
+	}
+
+	return t.Copy(t.records[i]), nil
+}
+
+// Save validates a record and inserts it, or replaces the record with the same primary key. The affected rows
+// follow INSERT..UPDATE ON DUPLICATE KEY: 1 for an insert and 2 for an update
+func (t *FakeTable[T]) Save(obj T) (sql.Result, error) {
+	err := obj.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "field validation error")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	existing := -1
+	if t.Key != nil {
+		existing = t.find(t.Key(obj))
+	}
+
+	for name, unique := range t.Unique {
+		values := unique(obj)
+		if values == nil {
+			continue
+		}
+		for i, record := range t.records {
+			if i != existing && equalValues(values, unique(record)) {
+				return nil, errors.Wrapf(ErrDuplicateKey, "save failed for %s: duplicate entry %v for key %s", t.Name, values, name)
+			}
+		}
+	}
+
+	if existing >= 0 && t.Version != nil {
+		if t.Version(obj, false) != t.Version(t.records[existing], false) {
+			return fakeResult{}, errors.Wrap(ErrStale, "save failed for "+t.Name)
+		}
+		t.Version(obj, true)
+	}
+
+	// the auto_increment value is only assigned once the record is known to be saved
+	var lastID int64
+	if t.AutoIncrement != nil {
+		id := t.AutoIncrement(obj, t.lastID+1)
+		if id > t.lastID {
+			t.lastID = id
+		}
+		lastID = id
+	}
+
+	if existing >= 0 {
+		t.records[existing] = t.Copy(obj)
+		return fakeResult{lastID: lastID, rows: 2}, nil
+	}
+
+	t.records = append(t.records, t.Copy(obj))
+	return fakeResult{lastID: lastID, rows: 1}, nil
+}
+
+// Delete removes the record with the same primary key, or marks it as deleted when SoftDelete is set
+func (t *FakeTable[T]) Delete(obj T) (sql.Result, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	i := t.find(t.Key(obj))
+	if i < 0 || t.deleted(t.records[i]) {
+		return fakeResult{}, nil
+	}
+
+	if t.SoftDelete != nil {
+		t.SoftDelete(t.records[i])
+		return fakeResult{rows: 1}, nil
+	}
+
+	t.records = append(t.records[:i], t.records[i+1:]...)
+	return fakeResult{rows: 1}, nil
+}
+
+// All returns every record, ordered & limited by the QueryOptions. A wrapped ErrNotFound is returned along with
+// the empty list when there are none
+func (t *FakeTable[T]) All(options ...QueryOptions) ([]T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	objects := make([]T, 0, len(t.records))
+	for _, record := range t.records {
+		if !t.deleted(record) {
+			objects = append(objects, t.Copy(record))
+		}
+	}
+
+	for _, option := range options {
+		if option.OrderBy != "" {
+			err := t.orderBy(objects, option.OrderBy)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if option.Limit != 0 && option.Limit < len(objects) {
+			objects = objects[:option.Limit]
+		}
+	}
+
+	if len(objects) == 0 {
+		return objects, errors.Wrap(ErrNotFound, "no records found")
+	}
+
+	return objects, nil
+}
+
+// deleted reports whether a record is soft deleted
+func (t *FakeTable[T]) deleted(obj T) bool {
+	return t.Deleted != nil && t.Deleted(obj)
+}
+
+// find returns the index of the record with the given primary key, or -1
+func (t *FakeTable[T]) find(key []interface{}) int {
+	for i, record := range t.records {
+		if equalValues(key, t.Key(record)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// orderBy sorts records by an ORDER BY clause such as "name DESC, id"
+func (t *FakeTable[T]) orderBy(objects []T, orderBy string) error {
+	type term struct {
+		column func(T) interface{}
+		desc   bool
+	}
+
+	var terms []term
+	for _, part := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 {
+			return fmt.Errorf("unsupported ORDER BY: %s", orderBy)
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		column, ok := t.Columns[strings.ToLower(strings.Trim(name, "` + "`" + `"))]
+		if !ok {
+			return fmt.Errorf("unknown column in ORDER BY: %s", fields[0])
+		}
+		desc := len(fields) == 2 && strings.EqualFold(fields[1], "DESC")
+		if len(fields) == 2 && !desc && !strings.EqualFold(fields[1], "ASC") {
+			return fmt.Errorf("unsupported ORDER BY: %s", orderBy)
+		}
+		terms = append(terms, term{column: column, desc: desc})
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		for _, term := range terms {
+			c := compareValues(term.column(objects[i]), term.column(objects[j]))
+			if c != 0 {
+				return c < 0 != term.desc
+			}
+		}
+		return false
+	})
+
+	return nil
+}
+
+// Equal determines whether two column values are the same, dereferencing pointers. It is used by the
+// generated tests to compare records read back from the database
+func Equal(a, b interface{}) bool {
+	return compareValues(a, b) == 0
+}
+
+// equalValues determines whether two keys hold the same values
+func equalValues(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if compareValues(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// compareValues orders two column values the way MySQL does for the common types, with NULL first
+func compareValues(a, b interface{}) int {
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	for av.Kind() == reflect.Ptr && !av.IsNil() {
+		av = av.Elem()
+	}
+	for bv.Kind() == reflect.Ptr && !bv.IsNil() {
+		bv = bv.Elem()
+	}
+
+	aNull := !av.IsValid() || av.Kind() == reflect.Ptr
+	bNull := !bv.IsValid() || bv.Kind() == reflect.Ptr
+	switch {
+	case aNull && bNull:
+		return 0
+	case aNull:
+		return -1
+	case bNull:
+		return 1
+	}
+
+	if at, ok := av.Interface().(time.Time); ok {
+		if bt, ok := bv.Interface().(time.Time); ok {
+			switch {
+			case at.Before(bt):
+				return -1
+			case at.After(bt):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	// types such as decimal.Decimal compare themselves
+	if cmp := av.MethodByName("Cmp"); cmp.IsValid() && cmp.Type().NumIn() == 1 && cmp.Type().In(0) == bv.Type() && cmp.Type().NumOut() == 1 && cmp.Type().Out(0).Kind() == reflect.Int {
+		return int(cmp.Call([]reflect.Value{bv})[0].Int())
+	}
+
+	if av.Kind() == bv.Kind() {
+		switch av.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(av.Int(), bv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return compareOrdered(av.Uint(), bv.Uint())
+		case reflect.Float32, reflect.Float64:
+			return compareOrdered(av.Float(), bv.Float())
+		case reflect.String:
+			return compareOrdered(av.String(), bv.String())
+		case reflect.Bool:
+			return compareOrdered(boolInt(av.Bool()), boolInt(bv.Bool()))
+		case reflect.Slice:
+			if av.Type().Elem().Kind() == reflect.Uint8 {
+				return bytes.Compare(av.Bytes(), bv.Bytes())
+			}
+		}
+	}
+
+	return compareOrdered(fmt.Sprint(av.Interface()), fmt.Sprint(bv.Interface()))
+}
+
+func compareOrdered[V int64 | uint64 | float64 | string](a, b V) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+`
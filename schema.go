@@ -0,0 +1,207 @@
+package gostruct
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// fingerprintPrefix starts the comment of a {table}_base.go file that holds the fingerprint of the schema the
+// file was generated from
+const fingerprintPrefix = "// Schema fingerprint: "
+
+// tableSchema is the metadata a table package is generated from
+type tableSchema struct {
+	Columns     []tableObj    `json:"columns"`
+	Indexes     []uniqueIndex `json:"indexes,omitempty"`
+	ForeignKeys []foreignKey  `json:"foreign_keys,omitempty"`
+	// View is set for views, which get read-only packages
+	View bool `json:"view,omitempty"`
+}
+
+// schemaSnapshot holds the metadata of the tables of a database, as stored in a schema snapshot file
+type schemaSnapshot struct {
+	Database string                 `json:"database"`
+	Tables   map[string]tableSchema `json:"tables"`
+}
+
+// fingerprint returns a hash of the metadata, which changes with any change of the schema that affects the
+// generated code
+func (s tableSchema) fingerprint() string {
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintParts returns short hashes of the unique indexes & foreign keys along with the view flag, which
+// follow the fingerprint in the {table}_base.go file so verify can tell which of them changed
+func (s tableSchema) fingerprintParts() string {
+	hash := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:4])
+	}
+	return "indexes=" + hash(s.Indexes) + " foreign_keys=" + hash(s.ForeignKeys) + " view=" + strconv.FormatBool(s.View)
+}
+
+// getTableSchema reads the metadata of a table from the information_schema
+func getTableSchema(con *sql.DB, database, table string) (tableSchema, error) {
+	var schema tableSchema
+
+	rows, err := con.Query("SELECT column_name, is_nullable, column_key, data_type, column_type, column_default, extra, character_maximum_length, numeric_precision, numeric_scale FROM information_schema.columns WHERE table_name = ? AND table_schema = ? ORDER BY ordinal_position", table, database)
+	if err != nil {
+		return schema, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object tableObj
+		err = rows.Scan(&object.Name, &object.IsNullable, &object.Key, &object.DataType, &object.ColumnType, &object.Default, &object.Extra, &object.CharMaxLength, &object.NumericPrecision, &object.NumericScale)
+		if err != nil {
+			return schema, err
+		}
+		schema.Columns = append(schema.Columns, object)
+	}
+	err = rows.Err()
+	if err != nil {
+		return schema, err
+	}
+
+	if len(schema.Columns) == 0 {
+		return schema, errors.New("No results for table: " + table)
+	}
+
+	// tinyint & smallint columns that only hold 0, 1 or NULL are mapped to bool
+	for i, object := range schema.Columns {
+		if object.DataType == "tinyint" || object.DataType == "smallint" {
+			schema.Columns[i].IsBool, err = isBoolColumn(con, database, table, object.Name)
+			if err != nil {
+				return schema, err
+			}
+		}
+	}
+
+	var tableType string
+	err = con.QueryRow("SELECT TABLE_TYPE FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?", database, table).Scan(&tableType)
+	if err != nil {
+		return schema, err
+	}
+	schema.View = tableType == "VIEW"
+
+	schema.Indexes, err = getUniqueIndexes(con, database, table)
+	if err != nil {
+		return schema, err
+	}
+
+	schema.ForeignKeys, err = getForeignKeys(con, database, table)
+	return schema, err
+}
+
+// isBoolColumn determines whether a column only holds 0, 1 or NULL
+func isBoolColumn(con *sql.DB, database, table, column string) (bool, error) {
+	rows, err := con.Query("SELECT DISTINCT(`" + column + "`) FROM " + database + "." + table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	isBool := true
+	for rows.Next() {
+		var uObj uniqueValues
+		rows.Scan(&uObj.Value)
+		if uObj.Value.String != "0" && uObj.Value.String != "1" && uObj.Value.String != "" {
+			isBool = false
+		}
+	}
+
+	return isBool, rows.Err()
+}
+
+// getTables returns every table of a database along with its type & comment
+func getTables(con *sql.DB, database string) ([]table, error) {
+	rows, err := con.Query("SELECT TABLE_NAME, TABLE_TYPE, TABLE_COMMENT FROM `information_schema`.`TABLES` WHERE `TABLE_SCHEMA` = ? ORDER BY TABLE_NAME", database)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []table
+	for rows.Next() {
+		var tbl table
+		err = rows.Scan(&tbl.Name, &tbl.Type, &tbl.Comment)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, tbl)
+	}
+
+	return tables, rows.Err()
+}
+
+// readSchema reads the metadata of the tables from the database. Every selected table is read when tables is
+// empty
+func (g Gostruct) readSchema(tables []string) (schemaSnapshot, error) {
+	snapshot := schemaSnapshot{Database: g.Database, Tables: map[string]tableSchema{}}
+
+	con, err := getConnection(g)
+	if err != nil {
+		return snapshot, err
+	}
+	defer con.Close()
+
+	if len(tables) == 0 {
+		all, err := getTables(con, g.Database)
+		if err != nil {
+			return snapshot, err
+		}
+		tables = g.selectTables(all)
+	}
+
+	for _, table := range tables {
+		snapshot.Tables[table], err = getTableSchema(con, g.Database, table)
+		if err != nil {
+			return snapshot, err
+		}
+	}
+
+	return snapshot, nil
+}
+
+// loadSchema reads a schema snapshot file
+func loadSchema(path string) (schemaSnapshot, error) {
+	var snapshot schemaSnapshot
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
+
+// saveSchema writes a schema snapshot file
+func saveSchema(path string, snapshot schemaSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, string(data)+"\n", true)
+}
+
+// tableNames returns the names of the tables of a snapshot in alphabetical order
+func (s schemaSnapshot) tableNames() []string {
+	var tables []string
+	for table := range s.Tables {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return tables
+}
@@ -0,0 +1,10 @@
+// Package money holds the custom type of the type override fixture
+package money
+
+import "database/sql/driver"
+
+// Amount is an amount in cents
+type Amount int64
+
+func (a *Amount) Scan(value interface{}) error { return nil }
+func (a Amount) Value() (driver.Value, error)  { return int64(a), nil }
@@ -0,0 +1,32 @@
+// Package mysql is a stub of github.com/go-sql-driver/mysql, used to type-check the generated code & run the
+// tests of the connection package
+package mysql
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+type MySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (me *MySQLError) Error() string { return me.Message }
+
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (nt *NullTime) Scan(value interface{}) error {
+	nt.Time, nt.Valid = value.(time.Time)
+	return nil
+}
+
+func (nt NullTime) Value() (driver.Value, error) {
+	if !nt.Valid {
+		return nil, nil
+	}
+	return nt.Time, nil
+}
@@ -0,0 +1,16 @@
+// Package errors is a stub of github.com/pkg/errors, used to type-check the generated code
+package errors
+
+import "errors"
+
+func New(message string) error { return errors.New(message) }
+
+func Wrap(err error, message string) error { return err }
+
+func Wrapf(err error, format string, args ...interface{}) error { return err }
+
+func Cause(err error) error { return err }
+
+func Is(err, target error) bool { return errors.Is(err, target) }
+
+func As(err error, target interface{}) bool { return errors.As(err, target) }
@@ -0,0 +1,44 @@
+// Package decimal is a stub of github.com/shopspring/decimal, used to type-check the generated code & run the
+// tests of the connection package. Decimals keep the text they were created or scanned from
+package decimal
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+type Decimal struct {
+	text string
+}
+
+func New(value int64, exp int32) Decimal { return Decimal{text: fmt.Sprintf("%de%d", value, exp)} }
+
+func (d Decimal) Abs() Decimal                     { return Decimal{text: strings.TrimPrefix(d.text, "-")} }
+func (d Decimal) Cmp(d2 Decimal) int               { return strings.Compare(d.text, d2.text) }
+func (d Decimal) Equal(d2 Decimal) bool            { return d.text == d2.text }
+func (d Decimal) Truncate(precision int32) Decimal { return d }
+func (d Decimal) String() string                   { return d.text }
+func (d Decimal) Value() (driver.Value, error)     { return d.text, nil }
+
+func (d Decimal) Sign() int {
+	switch {
+	case strings.HasPrefix(d.text, "-"):
+		return -1
+	case strings.Trim(d.text, "0.e") == "":
+		return 0
+	}
+	return 1
+}
+
+func (d *Decimal) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		d.text = string(v)
+	case string:
+		d.text = v
+	default:
+		return fmt.Errorf("cannot scan %T into Decimal", value)
+	}
+	return nil
+}
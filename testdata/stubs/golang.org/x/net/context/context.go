@@ -0,0 +1,8 @@
+// Package context is a stub of golang.org/x/net/context, used to type-check the generated code
+package context
+
+import "context"
+
+type Context = context.Context
+
+func Background() Context { return context.Background() }
@@ -0,0 +1,65 @@
+package User
+
+import (
+	"testing"
+	"time"
+
+	db "connection"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+)
+
+// TestFakeCopies checks that changing the pointers & slices of a record after saving or reading it doesn't
+// change the stored one
+func TestFakeCopies(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	age := int64(30)
+	obj := &User{Name: "a", Email: "a@b.c", Status: UserStatusActive, Seen: time.Now(), Age: &age, Hash: []byte{1, 2}}
+	_, err := fake.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*obj.Age, obj.Hash[0] = 31, 9
+	read, err := fake.ReadByKey(ctx, obj.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *read.Age != 30 || read.Hash[0] != 1 {
+		t.Fatalf("got age %d & hash %v after changing the saved record, want 30 & [1 2]", *read.Age, read.Hash)
+	}
+
+	*read.Age, read.Hash[0] = 32, 8
+	read, err = fake.ReadByKey(ctx, obj.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *read.Age != 30 || read.Hash[0] != 1 {
+		t.Errorf("got age %d & hash %v after changing the read record, want 30 & [1 2]", *read.Age, read.Hash)
+	}
+}
+
+// TestFakeRejectedSave checks that a save rejected by a unique index leaves the record & the auto_increment
+// value untouched
+func TestFakeRejectedSave(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	_, err := fake.Save(ctx, &User{Name: "a", Email: "a@b.c", Status: UserStatusActive, Seen: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj := &User{Name: "b", Email: "a@b.c", Status: UserStatusActive, Seen: time.Now()}
+	_, err = fake.Save(ctx, obj)
+	if !errors.Is(err, db.ErrDuplicateKey) || obj.Id != 0 {
+		t.Fatalf("got %v & id %d, want a duplicate key error & id 0", err, obj.Id)
+	}
+
+	obj.Email = "b@b.c"
+	_, err = fake.Save(ctx, obj)
+	if err != nil || obj.Id != 2 {
+		t.Errorf("got %v & id %d, want id 2", err, obj.Id)
+	}
+}
@@ -0,0 +1,70 @@
+package User
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TestSaveStatement checks that zero values are saved as is, while the empty auto_increment key is saved as
+// NULL and empty dates & enums are left out of the query. The key is left out of the update list, so a new
+// record that conflicts on another unique key doesn't set it to NULL
+func TestSaveStatement(t *testing.T) {
+	obj := &User{Name: "a", Email: "a@b.c", Status: UserStatusActive, Seen: time.Now()}
+	query, args := obj.saveStatement()
+	if query != saveQuery || strings.Contains(query, "`id` = ") || len(args) != len(saveColumns) {
+		t.Errorf("got query %s with %d arguments, want saveQuery without an id update", query, len(args))
+	}
+	if args[0] != nil || args[4] != false || args[6] != uint8(0) || args[11] != int64(0) || args[15] != float64(0) {
+		t.Errorf("got id %v, active %v, level %v, big %v & ratio %v, want <nil>, false, 0, 0 & 0", args[0], args[4], args[6], args[11], args[15])
+	}
+
+	obj.Status, obj.Seen = "", time.Time{}
+	query, args = obj.saveStatement()
+	if strings.Contains(query, "`status`") || strings.Contains(query, "`seen`") || len(args) != len(saveColumns)-2 {
+		t.Errorf("got %s with %d arguments, want status & seen left out", query, len(args))
+	}
+}
+
+// TestSaveFalse checks that a record saved with active set to false reads back as false. Big, umed, flags &
+// perms are NOT NULL without a default, and 0 is a valid value for them
+func TestSaveFalse(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+	obj := &User{Name: "a", Email: "a@b.c", Active: true, Status: UserStatusActive, Seen: time.Now()}
+	_, err := fake.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj.Active = false
+	_, err = fake.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := fake.ReadByKey(ctx, obj.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Active {
+		t.Error("got active, want inactive")
+	}
+}
+
+// TestSaveEmptyStrings checks that empty strings are saved as is. Name & email are NOT NULL without a
+// default, and "" is a valid value for them
+func TestSaveEmptyStrings(t *testing.T) {
+	obj := &User{Status: UserStatusActive, Seen: time.Now()}
+	err := obj.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewFake().Save(context.Background(), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,76 @@
+package User
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	db "connection"
+
+	"golang.org/x/net/context"
+)
+
+// recordDriver remembers the last query it ran and returns the ids 1 & 2
+type recordDriver struct{}
+
+type recordConn struct{}
+
+type recordStmt struct{ query string }
+
+type recordRows struct{ next int64 }
+
+var lastQuery string
+
+func (recordDriver) Open(name string) (driver.Conn, error)         { return recordConn{}, nil }
+func (recordConn) Prepare(query string) (driver.Stmt, error)       { return recordStmt{query}, nil }
+func (recordConn) Close() error                                    { return nil }
+func (recordConn) Begin() (driver.Tx, error)                       { return nil, driver.ErrSkip }
+func (recordStmt) Close() error                                    { return nil }
+func (recordStmt) NumInput() int                                   { return -1 }
+func (recordStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (r *recordRows) Columns() []string                            { return []string{"id"} }
+func (r *recordRows) Close() error                                 { return nil }
+
+func (s recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	lastQuery = s.query
+	return &recordRows{}, nil
+}
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if r.next == 2 {
+		return io.EOF
+	}
+	r.next++
+	dest[0] = r.next
+	return nil
+}
+
+func init() {
+	sql.Register("gostruct_record", recordDriver{})
+}
+
+// TestStreamByQueryOptions checks that StreamByQuery applies the ORDER BY & LIMIT of the QueryOptions
+func TestStreamByQueryOptions(t *testing.T) {
+	con, err := sql.Open("gostruct_record", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	var ids []int64
+	err = NewRepository(con).StreamByQuery(context.Background(), selectQuery+" WHERE id > ?", func(obj *User) error {
+		ids = append(ids, obj.Id)
+		return nil
+	}, 0, db.QueryOptions{OrderBy: "id", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := selectQuery + " WHERE id > ? ORDER BY id LIMIT 1"; lastQuery != want {
+		t.Errorf("got %s, want %s", lastQuery, want)
+	}
+	if len(ids) != 2 {
+		t.Errorf("got ids %v, want the 2 rows of the driver", ids)
+	}
+}
@@ -0,0 +1,436 @@
+package gostruct
+
+import (
+	"strconv"
+	"strings"
+)
+
+// testValue returns a Go expression holding a valid value for a column, along with the imports it needs.
+// Generated types are qualified by pkg. An empty expression is returned when the zero value has to do
+func testValue(table string, c fakeColumn, pkg string) (string, map[string]string) {
+	switch strings.TrimPrefix(c.Type, "*") {
+	case "string":
+		value := "test"
+		if c.Object.CharMaxLength.Valid && c.Object.CharMaxLength.Int64 < int64(len(value)) {
+			value = value[:c.Object.CharMaxLength.Int64]
+		}
+		return strconv.Quote(value), nil
+	case "int64", "uint8", "uint16", "uint32", "uint64":
+		return "1", nil
+	case "int16":
+		return "2017", nil
+	case "float64":
+		return "1.5", nil
+	case "bool":
+		return "true", nil
+	case "decimal.Decimal":
+		return "decimal.New(1, 0)", map[string]string{"github.com/shopspring/decimal": ""}
+	case "db.Bit":
+		return "db.Bit(1)", nil
+	case "db.Duration":
+		return "db.Duration{Duration: time.Hour}", map[string]string{"time": ""}
+	case "time.Time":
+		return "time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)", map[string]string{"time": ""}
+	case "[]byte":
+		if c.Object.DataType == "binary" && c.Object.CharMaxLength.Valid {
+			// binary columns are padded with zero bytes
+			return "make([]byte, " + strconv.FormatInt(c.Object.CharMaxLength.Int64, 10) + ")", nil
+		}
+		return `[]byte("test")`, nil
+	case "json.RawMessage":
+		return `json.RawMessage("{}")`, map[string]string{"encoding/json": ""}
+	case enumTypeName(table, c.Object.Name):
+		values := enumValues(c.Object.ColumnType)
+		idents := enumIdentifiers(values)
+		for i, value := range values {
+			if value != "" {
+				return pkg + enumTypeName(table, c.Object.Name) + idents[i], nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// exampleName returns the name of the example for a function, or for a method when method is set. Names that
+// contain an underscore can't be told apart from the example suffix, so they are documented as package examples
+func exampleName(ident, method string) string {
+	name := ident
+	if method != "" {
+		name += "_" + method
+	}
+	if !strings.Contains(ident, "_") && !strings.Contains(method, "_") {
+		return "Example" + name
+	}
+
+	if method != "" {
+		ident = method
+	}
+	return "Example_" + lowercaseFirst(ident)
+}
+
+// buildTest builds the {table}_base_test.go file with round-trip tests against the repository interface, and
+// the examples_test.go file with an example for every generated function
+func (g Gostruct) buildTest(table string, features TableFeatures, columns []fakeColumn, primaryKeys []string, funcName string) error {
+	tableNaming := uppercaseFirst(table)
+	dir := g.modelDir + "/" + tableNaming + "/"
+
+	imports := map[string]string{
+		g.dbDir:                    "db",
+		"os":                       "",
+		"database/sql":             "",
+		"testing":                  "",
+		"github.com/pkg/errors":    "",
+		"golang.org/x/net/context": "",
+	}
+	exampleImports := map[string]string{
+		g.dbDir:                    "db",
+		g.modelImport(table):       "",
+		"fmt":                      "",
+		"golang.org/x/net/context": "",
+	}
+
+	var fields, checks, enumField, enumType, updateField, updateValue string
+	var enumNullable bool
+	var keyFields []string
+	for _, c := range columns {
+		name := uppercaseFirst(c.Object.Name)
+		nullable := c.Object.IsNullable == "YES"
+		isKey := features.isKey(c.Object)
+		value, valueImports := testValue(table, c, "")
+
+		if isKey {
+			exampleValue, keyImports := testValue(table, c, tableNaming+".")
+			if exampleValue == "" {
+				exampleValue, keyImports = "*new("+c.Type+")", c.Imports
+			}
+			for path, alias := range keyImports {
+				exampleImports[path] = alias
+			}
+			keyFields = append(keyFields, name+": "+exampleValue)
+		}
+
+		switch {
+		case nullable:
+			// nullable columns are left NULL to make sure NULL is preserved
+			if strings.HasPrefix(c.Type, "*") {
+				checks += `
+	if got.` + name + ` != nil {
+		t.Errorf("` + c.Object.Name + `: got %v, want NULL", *got.` + name + `)
+	}`
+			} else {
+				checks += `
+	if got.` + name + ` != nil {
+		t.Errorf("` + c.Object.Name + `: got %v, want NULL", got.` + name + `)
+	}`
+			}
+		case value != "" && !(isKey && strings.Contains(c.Object.Extra.String, "auto_increment")):
+			fields += "\n\t\t" + name + ": " + value + ","
+			for path, alias := range valueImports {
+				imports[path] = alias
+			}
+			fallthrough
+		default:
+			checks += `
+	if !db.Equal(got.` + name + `, obj.` + name + `) {
+		t.Errorf("` + c.Object.Name + `: got %v, want %v", got.` + name + `, obj.` + name + `)
+	}`
+		}
+
+		// Save increments the version itself
+		if !nullable && !isKey && updateField == "" && c.Object.Name != features.Version {
+			switch c.Type {
+			case "string":
+				updateField, updateValue = name, "updated"
+				if c.Object.CharMaxLength.Valid && c.Object.CharMaxLength.Int64 < int64(len(updateValue)) {
+					updateValue = updateValue[:c.Object.CharMaxLength.Int64]
+				}
+				updateValue = strconv.Quote(updateValue)
+			case "int64":
+				updateField, updateValue = name, "2"
+			}
+		}
+
+		if c.Object.DataType == "enum" && enumField == "" && strings.TrimPrefix(c.Type, "*") == enumTypeName(table, c.Object.Name) {
+			enumField, enumType, enumNullable = name, enumTypeName(table, c.Object.Name), nullable
+		}
+	}
+
+	keyExpr := "obj.PrimaryKey()"
+	exampleKey := tableNaming + "." + tableNaming + "Key{" + strings.Join(keyFields, ", ") + "}"
+	if len(primaryKeys) == 1 {
+		keyExpr, exampleKey = "obj."+uppercaseFirst(primaryKeys[0]), strings.TrimPrefix(keyFields[0], uppercaseFirst(primaryKeys[0])+": ")
+	}
+
+	examples := `package ` + tableNaming + `_test` + importBlock(exampleImports)
+	if len(primaryKeys) > 0 {
+		examples += `
+
+func ` + exampleName("Read"+funcName+"ByKey", "") + `() {
+	obj, err := ` + tableNaming + `.Read` + funcName + `ByKey(context.Background(), ` + exampleKey + `)
+	if err != nil {
+		// db.ErrNotFound when there is no such record
+		return
+	}
+
+	fmt.Println(obj)
+}`
+	}
+	if len(primaryKeys) > 0 && !features.ReadOnly {
+		examples += `
+
+func ` + exampleName(tableNaming, funcName+"Save") + `() {
+	obj := &` + tableNaming + `.` + tableNaming + `{}
+
+	// inserts the record, or updates it when the key already exists
+	_, err := obj.` + funcName + `Save(context.Background())
+	if err != nil {
+		// *db.ValidationError when a value doesn't fit its column
+		return
+	}
+
+	fmt.Println(obj)
+}
+
+func ` + exampleName(tableNaming, funcName+"Delete") + `() {
+	obj, err := ` + tableNaming + `.Read` + funcName + `ByKey(context.Background(), ` + exampleKey + `)
+	if err != nil {
+		return
+	}
+
+	_, err = obj.` + funcName + `Delete(context.Background())
+	if err != nil {
+		return
+	}
+}`
+	}
+
+	examples += `
+
+func ` + exampleName("ReadAll"+funcName, "") + `() {
+	objects, err := ` + tableNaming + `.ReadAll` + funcName + `(context.Background(), db.QueryOptions{Limit: 10})
+	if err != nil {
+		return
+	}
+
+	for _, obj := range objects {
+		fmt.Println(obj)
+	}
+}
+
+func ` + exampleName("Read"+funcName+"ByQuery", "") + `() {
+	objects, err := ` + tableNaming + `.Read` + funcName + `ByQuery(context.Background(), "SELECT * FROM ` + table + ` LIMIT ?", 10)
+	if err != nil {
+		return
+	}
+
+	for _, obj := range objects {
+		fmt.Println(obj)
+	}
+}
+
+func ` + exampleName("ReadOne"+funcName+"ByQuery", "") + `() {
+	obj, err := ` + tableNaming + `.ReadOne` + funcName + `ByQuery(context.Background(), "SELECT * FROM ` + table + ` LIMIT 1")
+	if err != nil {
+		return
+	}
+
+	fmt.Println(obj)
+}
+
+func ` + exampleName("Stream"+funcName+"ByQuery", "") + `() {
+	err := ` + tableNaming + `.Stream` + funcName + `ByQuery(context.Background(), "SELECT * FROM ` + table + `", func(obj *` + tableNaming + `.` + tableNaming + `) error {
+		fmt.Println(obj)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+}
+
+func ` + exampleName(funcName+"Exec", "") + `() {
+	res, err := ` + tableNaming + `.` + funcName + `Exec(context.Background(), "DELETE FROM ` + table + ` WHERE 1 = 0")
+	if err != nil {
+		return
+	}
+
+	fmt.Println(res.RowsAffected())
+}
+
+func ` + exampleName("NewRepository", "") + `() {
+	con, err := db.Get("` + g.Database + `")
+	if err != nil {
+		return
+	}
+
+	var repo ` + tableNaming + `.` + tableNaming + `Repository = ` + tableNaming + `.NewRepository(con)
+	objects, err := repo.ReadAll(context.Background())
+	if err != nil {
+		return
+	}
+
+	fmt.Println(len(objects))
+}
+
+func ` + exampleName("NewFake", "") + `() {
+	var repo ` + tableNaming + `.` + tableNaming + `Repository = ` + tableNaming + `.NewFake()
+	_, err := repo.ReadAll(context.Background())
+	fmt.Println(err != nil)
+	// Output: true
+}
+`
+
+	err := g.writeGoFile(dir+"examples_test.go", examples, true)
+	if err != nil {
+		return err
+	}
+
+	// the round trip needs Save & Delete
+	if len(primaryKeys) == 0 || features.ReadOnly {
+		return nil
+	}
+
+	contents := `package ` + tableNaming + importBlock(imports) + `
+
+// testRepository returns the repository the generated tests run against. The in-memory fake is used, unless
+// GOSTRUCT_TEST_DSN holds the DSN of a test database (with parseTime=true). The tests then run in a
+// transaction that is rolled back
+func testRepository(t *testing.T) ` + tableNaming + `Repository {
+	dsn := os.Getenv("GOSTRUCT_TEST_DSN")
+	if dsn == "" {
+		return NewFake()
+	}
+
+	con, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := con.Begin()
+	if err != nil {
+		con.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		con.Close()
+	})
+
+	// the test record doesn't reference existing rows
+	_, err = tx.Exec("SET FOREIGN_KEY_CHECKS = 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewRepository(tx)
+}
+
+// testRecord returns a(n) ` + tableNaming + ` with a valid value for every required column and NULL for every
+// nullable column
+func testRecord() *` + tableNaming + ` {
+	return &` + tableNaming + `{` + fields + `
+	}
+}
+
+// TestRoundTrip saves a new record, reads it back, updates it and deletes it
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepository(t)
+
+	obj := testRecord()
+	_, err := repo.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.ReadByKey(ctx, ` + keyExpr + `)
+	if err != nil {
+		t.Fatal(err)
+	}` + checks
+
+	if updateField != "" {
+		contents += `
+
+	obj.` + updateField + ` = ` + updateValue + `
+	_, err = repo.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = repo.ReadByKey(ctx, ` + keyExpr + `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.` + updateField + ` != obj.` + updateField + ` {
+		t.Errorf("update: got %v, want %v", got.` + updateField + `, obj.` + updateField + `)
+	}`
+	}
+
+	contents += `
+
+	_, err = repo.Delete(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.ReadByKey(ctx, ` + keyExpr + `)
+	if !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("read after delete: got %v, want db.ErrNotFound", err)
+	}
+}`
+
+	if features.Version != "" {
+		contents += `
+
+// TestStaleSave makes sure Save rejects a record that was updated since it was read
+func TestStaleSave(t *testing.T) {
+	ctx := context.Background()
+	repo := testRepository(t)
+
+	obj := testRecord()
+	_, err := repo.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := repo.ReadByKey(ctx, ` + keyExpr + `)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Save(ctx, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Save(ctx, stale)
+	if !errors.Is(err, db.ErrStale) {
+		t.Errorf("got %v, want db.ErrStale", err)
+	}
+}`
+	}
+
+	if enumField != "" {
+		assign := `
+	obj.` + enumField + ` = "gostruct_invalid"`
+		if enumNullable {
+			assign = `
+	invalid := ` + enumType + `("gostruct_invalid")
+	obj.` + enumField + ` = &invalid`
+		}
+
+		contents += `
+
+// TestEnumRejected makes sure Save rejects a value that the enum column doesn't allow
+func TestEnumRejected(t *testing.T) {
+	obj := testRecord()` + assign + `
+
+	_, err := testRepository(t).Save(context.Background(), obj)
+	if !errors.Is(err, db.ErrValidation) {
+		t.Errorf("got %v, want db.ErrValidation", err)
+	}
+}`
+	}
+
+	testFilePath := dir + tableNaming + "_base_test.go"
+	return g.writeGoFile(testFilePath, contents, true)
+}
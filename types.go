@@ -0,0 +1,186 @@
+package gostruct
+
+import (
+	"sort"
+	"strings"
+)
+
+// fieldType is the Go representation of a column in the model struct and in the nilable struct that rows are
+// scanned into
+type fieldType struct {
+	// Type is the type of the model field
+	Type string
+	// NilType is the type of the nilable field
+	NilType string
+	// Convert turns the nilable field, substituted for %s, into the model field
+	Convert string
+	// Imports maps the import paths the types depend on to their alias
+	Imports map[string]string
+}
+
+// TypeOverride maps a specific column or every column of a database type to a custom Go type, which has to
+// implement sql.Scanner & driver.Valuer
+type TypeOverride struct {
+	// Column is the table.column the override applies to, e.g. orders.total
+	Column string `yaml:"column"`
+	// DBType is the column type or data type the override applies to, e.g. binary(16)
+	DBType string `yaml:"db_type"`
+	// GoType is the qualified Go type, e.g. uuid.UUID
+	GoType string `yaml:"go_type"`
+	// Import is the import path of the Go type, e.g. github.com/google/uuid
+	Import string `yaml:"import"`
+}
+
+// overrideType returns the Go types of a column according to the configured type overrides. Column overrides
+// take precedence over database type overrides
+func (g Gostruct) overrideType(table string, object tableObj) (fieldType, bool) {
+	var match *TypeOverride
+	for i, o := range g.TypeOverrides {
+		if strings.EqualFold(o.Column, table+"."+object.Name) {
+			match = &g.TypeOverrides[i]
+			break
+		}
+		if match == nil && o.Column == "" && (strings.EqualFold(o.DBType, object.ColumnType) || strings.EqualFold(o.DBType, object.DataType)) {
+			match = &g.TypeOverrides[i]
+		}
+	}
+	if match == nil {
+		return fieldType{}, false
+	}
+
+	t := pointerWrapped(match.GoType, object.IsNullable == "YES")
+	if match.Import != "" {
+		t.Imports = map[string]string{match.Import: importAlias(match.Import, match.GoType)}
+	}
+
+	return t, true
+}
+
+// columnType returns the Go types of a column, and whether they come from a type override
+func (g Gostruct) columnType(table string, object tableObj) (fieldType, bool) {
+	if t, ok := g.overrideType(table, object); ok {
+		return t, true
+	}
+
+	// bool primary keys stay numeric
+	return mapType(table, object, object.IsBool && object.Key != "PRI"), false
+}
+
+// importAlias returns the alias needed to refer to an import path by the package qualifier of a Go type
+func importAlias(path, goType string) string {
+	qualifier := strings.TrimLeft(goType, "*[]")
+	if i := strings.Index(qualifier, "."); i >= 0 {
+		qualifier = qualifier[:i]
+	} else {
+		return ""
+	}
+
+	if qualifier == path[strings.LastIndex(path, "/")+1:] {
+		return ""
+	}
+	return qualifier
+}
+
+// mapType returns the Go types of a column. tinyint & smallint columns that only hold 0 and 1 are treated as bool
+func mapType(table string, object tableObj, isBool bool) fieldType {
+	nullable := object.IsNullable == "YES"
+	unsigned := strings.Contains(object.ColumnType, "unsigned")
+
+	switch object.DataType {
+	case "tinyint", "smallint", "mediumint", "int", "bigint":
+		if isBool && (object.DataType == "tinyint" || object.DataType == "smallint") {
+			return nullWrapped("bool", "sql.NullBool", "Bool", nullable)
+		}
+		if unsigned {
+			return pointerWrapped(unsignedType(object.DataType), nullable)
+		}
+		return nullWrapped("int64", "sql.NullInt64", "Int64", nullable)
+	case "year":
+		return pointerWrapped("int16", nullable)
+	case "float", "double":
+		return nullWrapped("float64", "sql.NullFloat64", "Float64", nullable)
+	case "decimal":
+		t := pointerWrapped("decimal.Decimal", nullable)
+		t.Imports = map[string]string{"github.com/shopspring/decimal": ""}
+		return t
+	case "bit":
+		return pointerWrapped("db.Bit", nullable)
+	case "time":
+		return pointerWrapped("db.Duration", nullable)
+	case "date", "datetime", "timestamp":
+		t := nullWrapped("time.Time", "mysql.NullTime", "Time", nullable)
+		t.Imports = map[string]string{"time": ""}
+		if nullable {
+			t.Imports["github.com/go-sql-driver/mysql"] = ""
+		}
+		return t
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		// a nil slice is NULL
+		return fieldType{Type: "[]byte", NilType: "[]byte", Convert: "%s"}
+	case "json":
+		// a nil message is NULL
+		return fieldType{Type: "json.RawMessage", NilType: "[]byte", Convert: "json.RawMessage(%s)", Imports: map[string]string{"encoding/json": ""}}
+	case "enum", "set":
+		// the enum & set types scan themselves
+		return pointerWrapped(enumTypeName(table, object.Name), nullable)
+	}
+
+	return nullWrapped("string", "sql.NullString", "String", nullable)
+}
+
+// unsignedType returns the smallest unsigned Go type that holds an unsigned integer column
+func unsignedType(dataType string) string {
+	switch dataType {
+	case "tinyint":
+		return "uint8"
+	case "smallint":
+		return "uint16"
+	case "mediumint", "int":
+		return "uint32"
+	}
+	return "uint64"
+}
+
+// nullWrapped returns a type that is scanned through one of the sql.Null* wrappers when the column is nullable.
+// The wrapper is converted by the matching connection.{name}Ptr helper, which returns nil for NULL
+func nullWrapped(goType, nullType, name string, nullable bool) fieldType {
+	if !nullable {
+		return fieldType{Type: goType, NilType: goType, Convert: "%s"}
+	}
+	return fieldType{Type: "*" + goType, NilType: nullType, Convert: "db." + name + "Ptr(%s)"}
+}
+
+// pointerWrapped returns a type that is scanned straight into a pointer when the column is nullable, which
+// database/sql leaves nil for NULL
+func pointerWrapped(goType string, nullable bool) fieldType {
+	if nullable {
+		goType = "*" + goType
+	}
+	return fieldType{Type: goType, NilType: goType, Convert: "%s"}
+}
+
+// importBlock returns the import declaration for a set of import paths, with the standard library first
+func importBlock(imports map[string]string) string {
+	var std, external []string
+	for path, alias := range imports {
+		line := "\t"
+		if alias != "" {
+			line += alias + " "
+		}
+		line += `"` + path + `"`
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			external = append(external, line)
+		} else {
+			std = append(std, line)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(external)
+
+	block := "\n\nimport (\n" + strings.Join(std, "\n")
+	if len(external) > 0 {
+		block += "\n\n" + strings.Join(external, "\n")
+	}
+
+	return block + "\n)"
+}
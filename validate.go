@@ -0,0 +1,140 @@
+package gostruct
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// intRanges holds the range of each signed integer column type that is wider than its Go type allows
+var intRanges = map[string][2]int64{
+	"tinyint":   {-128, 127},
+	"smallint":  {-32768, 32767},
+	"mediumint": {-8388608, 8388607},
+	"int":       {-2147483648, 2147483647},
+}
+
+// timeRanges holds the first & last value each temporal column type can store, as time.Date arguments
+// followed by the range in the MySQL format
+var timeRanges = map[string][3]string{
+	"date":      {"1000, 1, 1, 0, 0, 0, 0", "9999, 12, 31, 23, 59, 59, 999999999", "1000-01-01 and 9999-12-31"},
+	"datetime":  {"1000, 1, 1, 0, 0, 0, 0", "9999, 12, 31, 23, 59, 59, 999999999", "1000-01-01 00:00:00 and 9999-12-31 23:59:59"},
+	"timestamp": {"1970, 1, 1, 0, 0, 1, 0", "2038, 1, 19, 3, 14, 7, 999999999", "1970-01-01 00:00:01 and 2038-01-19 03:14:07 UTC"},
+}
+
+// buildValidation returns the statements of the Validate method that check a single column against its
+// definition. Only the first violation of a column is reported. Columns with a type override are only
+// checked for a missing value
+func buildValidation(table string, object tableObj, t fieldType, overridden bool, imports map[string]string) string {
+	field := "obj." + uppercaseFirst(object.Name)
+	nullable := object.IsNullable == "YES"
+	goType := strings.TrimPrefix(t.Type, "*")
+
+	var defaultVal string
+	if strings.ToLower(object.Default.String) != "null" {
+		defaultVal = object.Default.String
+	}
+
+	var checks string
+	add := func(cond, message string) {
+		if nullable && t.Type != goType {
+			if strings.Contains(cond, "||") {
+				cond = "(" + cond + ")"
+			}
+			cond = field + " != nil && " + cond
+		}
+		if checks == "" {
+			checks = "\n\tif "
+		} else {
+			checks = strings.TrimSuffix(checks, "\n\t}") + "\n\t} else if "
+		}
+		checks += cond + ` {
+		fields = append(fields, db.FieldError{Column: "` + object.Name + `", Message: ` + message + `})
+	}`
+	}
+
+	// values are dereferenced once the nil check passed. Methods are called on the field itself, since Go
+	// dereferences the pointer for value receivers
+	value := field
+	if nullable && t.Type != goType {
+		value = "*" + field
+	}
+
+	// a column without a default needs a value. Only empty dates & enums count as missing, since "", 0 & false
+	// are real values, while Save leaves empty dates & enums out so they take the default of their column. An
+	// enum that allows '' takes "" as a real value as well
+	emptyEnum := object.DataType == "enum" && !inArray("", enumValues(object.ColumnType))
+	if !nullable && object.Key != "PRI" && defaultVal == "" && (goType == "time.Time" || emptyEnum) {
+		if zero := zeroCheck(table, object, goType, field); zero != "" {
+			add(zero, `"a value must be provided"`)
+		}
+	}
+	if overridden {
+		return checks
+	}
+
+	switch object.DataType {
+	case "char", "varchar":
+		if object.CharMaxLength.Valid {
+			imports["unicode/utf8"] = ""
+			max := strconv.FormatInt(object.CharMaxLength.Int64, 10)
+			add("utf8.RuneCountInString("+value+") > "+max, `"must be at most `+max+` characters"`)
+		}
+	case "binary", "varbinary":
+		if object.CharMaxLength.Valid {
+			max := strconv.FormatInt(object.CharMaxLength.Int64, 10)
+			add("len("+value+") > "+max, `"must be at most `+max+` bytes"`)
+		}
+	case "tinyint", "smallint", "mediumint", "int":
+		if goType == "int64" {
+			r := intRanges[object.DataType]
+			min, max := strconv.FormatInt(r[0], 10), strconv.FormatInt(r[1], 10)
+			add(value+" < "+min+" || "+value+" > "+max, `"must be between `+min+` and `+max+`"`)
+		} else if goType == "uint32" && object.DataType == "mediumint" {
+			add(value+" > 16777215", `"must be at most 16777215"`)
+		}
+	case "year":
+		add(value+" != 0 && ("+value+" < 1901 || "+value+" > 2155)", `"must be between 1901 and 2155"`)
+	case "decimal":
+		if object.NumericPrecision.Valid && object.NumericScale.Valid {
+			digits := object.NumericPrecision.Int64 - object.NumericScale.Int64
+			scale := strconv.FormatInt(object.NumericScale.Int64, 10)
+			add(field+".Abs().Cmp(decimal.New(1, "+strconv.FormatInt(digits, 10)+")) >= 0", `"must have at most `+strconv.FormatInt(digits, 10)+` digits before the decimal point"`)
+			add("!"+field+".Equal("+field+".Truncate("+scale+"))", `"must have at most `+scale+` decimal places"`)
+		}
+		if strings.Contains(object.ColumnType, "unsigned") {
+			add(field+".Sign() < 0", `"must not be negative"`)
+		}
+	case "enum":
+		add(value+` != "" && !`+field+".Valid()", fmt.Sprintf(`fmt.Sprintf("invalid value %%q, possible values are: %%s", %s, %q)`, value, strings.Join(enumValues(object.ColumnType), ", ")))
+	case "set":
+		add("!"+field+".Valid()", strconv.Quote("contains unknown members, possible members are: "+strings.Join(enumValues(object.ColumnType), ", ")))
+	case "date", "datetime", "timestamp":
+		r := timeRanges[object.DataType]
+		min, max := "time.Date("+r[0]+", time.UTC)", "time.Date("+r[1]+", time.UTC)"
+		add("!"+field+".IsZero() && ("+field+".Before("+min+") || "+field+".After("+max+"))", `"must be between `+r[2]+`"`)
+	}
+
+	return checks
+}
+
+// zeroCheck returns the condition under which the connection package treats a field as empty, or an empty
+// string when it never does
+func zeroCheck(table string, object tableObj, goType, field string) string {
+	switch goType {
+	case "string":
+		return field + ` == ""`
+	case "bool":
+		return "!" + field
+	case "int64", "int16", "uint8", "uint16", "uint32", "uint64", "float64", "db.Bit":
+		return field + " == 0"
+	case "time.Time":
+		return field + ".IsZero()"
+	case enumTypeName(table, object.Name):
+		if object.DataType == "set" {
+			return field + " == 0"
+		}
+		return field + ` == ""`
+	}
+	return ""
+}
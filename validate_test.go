@@ -0,0 +1,26 @@
+package gostruct
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidationRequiresValue(t *testing.T) {
+	for _, test := range []struct {
+		object   tableObj
+		required bool
+	}{
+		{column("status", "NO", "", "enum", "enum('active','inactive')", "", ""), true},
+		{column("status", "NO", "", "enum", "enum('','active')", "", ""), false},
+		{column("status", "NO", "", "enum", "enum('active','inactive')", "active", ""), false},
+		{column("name", "NO", "", "varchar", "varchar(45)", "", ""), false},
+		{column("seen", "NO", "", "datetime", "datetime", "", ""), true},
+	} {
+		g := Gostruct{}
+		fieldType, overridden := g.columnType("user", test.object)
+		checks := buildValidation("user", test.object, fieldType, overridden, map[string]string{})
+		if got := strings.Contains(checks, "a value must be provided"); got != test.required {
+			t.Errorf("%s: got required %v, want %v", test.object.ColumnType, got, test.required)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package gostruct
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// modelColumn is a field of a generated model
+type modelColumn struct {
+	GoType string
+	Tag    reflect.StructTag
+}
+
+// verify compares the generated models of the tables with the schema of the database, or with the schema
+// snapshot in schemaFile, and prints the differences. Every table of the schema that matches the include &
+// exclude patterns is compared when tables is empty. The schema is written to saveSchemaFile when it is set.
+// It reports whether every model is up to date
+func (g Gostruct) verify(tables []string, schemaFile, saveSchemaFile string) (bool, error) {
+	var snapshot schemaSnapshot
+	var err error
+	if schemaFile != "" {
+		snapshot, err = loadSchema(schemaFile)
+	} else {
+		if g.Database == "" || g.Host == "" {
+			return false, errors.New("You must include the 'db' and 'host' flag, or a 'schema' snapshot")
+		}
+		snapshot, err = g.readSchema(tables)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if saveSchemaFile != "" {
+		err = saveSchema(saveSchemaFile, snapshot)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if len(tables) == 0 {
+		for _, table := range snapshot.tableNames() {
+			if g.includeTable(table) {
+				tables = append(tables, table)
+			}
+		}
+	}
+
+	var stale int
+	for _, table := range tables {
+		schema, ok := snapshot.Tables[table]
+		if !ok {
+			return false, errors.New("No results for table: " + table)
+		}
+
+		diffs, err := g.verifyTable(table, schema)
+		if err != nil {
+			return false, err
+		}
+		if len(diffs) > 0 {
+			stale++
+			fmt.Println(table + ":")
+			for _, diff := range diffs {
+				fmt.Println("\t" + diff)
+			}
+		}
+	}
+
+	if stale > 0 {
+		fmt.Println(stale, "of", len(tables), "models are stale, regenerate them")
+		return false, nil
+	}
+
+	fmt.Println(len(tables), "models are up to date")
+	return true, nil
+}
+
+// verifyTable compares the schema of a table with its {table}_base.go file and returns the differences.
+// The columns are only compared when the schema fingerprint in the file differs
+func (g Gostruct) verifyTable(table string, schema tableSchema) ([]string, error) {
+	tableNaming := uppercaseFirst(table)
+	path := g.modelDir + "/" + tableNaming + "/" + tableNaming + "_base.go"
+	if !exists(path) {
+		return []string{"the model has not been generated"}, nil
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// the fingerprint is followed by the hashes of its parts, which older files don't have
+	var fingerprint, parts string
+	for _, group := range file.Comments {
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, fingerprintPrefix) {
+				fingerprint, parts, _ = strings.Cut(strings.TrimPrefix(comment.Text, fingerprintPrefix), " ")
+			}
+		}
+	}
+	if fingerprint == schema.fingerprint() {
+		return nil, nil
+	}
+
+	columns, names := modelColumns(file, tableNaming)
+
+	var diffs []string
+	inSchema := map[string]bool{}
+	for _, object := range schema.Columns {
+		if inSchema[object.Name] {
+			continue
+		}
+		inSchema[object.Name] = true
+
+		column, ok := columns[object.Name]
+		if !ok {
+			diffs = append(diffs, "column "+object.Name+" is missing from the model")
+			continue
+		}
+
+		var defaultVal string
+		if strings.ToLower(object.Default.String) != "null" {
+			defaultVal = object.Default.String
+		}
+		goType, _ := g.columnType(table, object)
+
+		for _, d := range []struct {
+			property, model, schema string
+		}{
+			{"type", column.Tag.Get("type"), object.ColumnType},
+			{"null", column.Tag.Get("null"), object.IsNullable},
+			{"key", column.Tag.Get("key"), object.Key},
+			{"default", column.Tag.Get("default"), defaultVal},
+			{"extra", column.Tag.Get("extra"), object.Extra.String},
+			{"Go type", column.GoType, goType.Type},
+		} {
+			if d.model != d.schema {
+				diffs = append(diffs, fmt.Sprintf("column %s: %s is %q in the model, %q in the schema", object.Name, d.property, d.model, d.schema))
+			}
+		}
+	}
+
+	for _, name := range names {
+		if !inSchema[name] {
+			diffs = append(diffs, "column "+name+" no longer exists")
+		}
+	}
+
+	if len(diffs) > 0 {
+		return diffs, nil
+	}
+	if fingerprint == "" {
+		return []string{"the model has no schema fingerprint"}, nil
+	}
+
+	var order []string
+	for _, object := range schema.Columns {
+		if !inArray(object.Name, order) {
+			order = append(order, object.Name)
+		}
+	}
+	if strings.Join(order, ",") != strings.Join(names, ",") {
+		diffs = append(diffs, "the column order differs from the schema")
+	}
+
+	kind, modelKind := "a table", "a view"
+	if schema.View {
+		kind, modelKind = modelKind, kind
+	}
+	messages := []string{
+		"the unique indexes differ from the schema",
+		"the foreign keys differ from the schema",
+		"the model was generated from " + modelKind + ", the schema holds " + kind,
+	}
+	modelParts, schemaParts := strings.Fields(parts), strings.Fields(schema.fingerprintParts())
+	for i, message := range messages {
+		if i < len(modelParts) && modelParts[i] != schemaParts[i] {
+			diffs = append(diffs, message)
+		}
+	}
+
+	if len(diffs) == 0 {
+		diffs = append(diffs, "the schema fingerprint differs from the model")
+	}
+
+	return diffs, nil
+}
+
+// modelColumns returns the fields of the model struct in a generated file by column name, along with the
+// column names in the order of the fields
+func modelColumns(file *ast.File, tableNaming string) (map[string]modelColumn, []string) {
+	columns := map[string]modelColumn{}
+	var names []string
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != tableNaming {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			column := modelColumn{GoType: types.ExprString(field.Type), Tag: reflect.StructTag(tag)}
+			name := column.Tag.Get("column")
+			columns[name] = column
+			names = append(names, name)
+		}
+		return false
+	})
+
+	return columns, names
+}